@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAgentSpec is used when neither the flag nor the PRD selects an agent
+const DefaultAgentSpec = "opencode"
+
+// AgentRunner launches the coding agent for a single iteration
+type AgentRunner interface {
+	// Name identifies the runner in the UI
+	Name() string
+	// Start launches the agent in dir with the given prompt
+	Start(prompt, dir string) (AgentProcess, error)
+}
+
+// AgentProcess is a running agent started by an AgentRunner
+type AgentProcess interface {
+	Stdout() io.Reader
+	Stderr() io.Reader
+	// Wait blocks until the agent exits and returns its exit code. The error
+	// is only set when the agent could not be waited on at all.
+	Wait() (int, error)
+	// Kill stops the agent and everything it spawned immediately
+	Kill() error
+	// Terminate asks the agent to exit and kills it if it is still running
+	// after grace. It does not block.
+	Terminate(grace time.Duration) error
+	// Suspend and Resume freeze and thaw the agent and its children
+	Suspend() error
+	Resume() error
+}
+
+// ParseAgentSpec builds a runner from a spec string:
+//
+//	opencode[:<args>]     opencode run [args] <prompt>; with --format json the
+//	                      Output panel shows its tool calls as foldable blocks
+//	command:<cmd> [args]  any command; prompt on stdin, or in place of a {prompt} argument
+//	fake:<script>         replays a scripted transcript, for tests
+func ParseAgentSpec(spec string) (AgentRunner, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = DefaultAgentSpec
+	}
+
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "opencode":
+		return &OpencodeRunner{ExtraArgs: splitCommandLine(arg)}, nil
+	case "command":
+		args := splitCommandLine(arg)
+		if len(args) == 0 {
+			return nil, errors.New("command agent needs a command, e.g. command:claude -p")
+		}
+		return &CommandRunner{Path: args[0], Args: args[1:]}, nil
+	case "fake":
+		if arg == "" {
+			return nil, errors.New("fake agent needs a script path, e.g. fake:testdata/run.txt")
+		}
+		return NewScriptedRunner(arg)
+	default:
+		return nil, fmt.Errorf("unknown agent %q (want opencode, command:<cmd> or fake:<script>)", spec)
+	}
+}
+
+// OpencodeRunner runs `opencode run <prompt>`
+type OpencodeRunner struct {
+	ExtraArgs []string
+}
+
+func (r *OpencodeRunner) Name() string {
+	return "opencode"
+}
+
+func (r *OpencodeRunner) Start(prompt, dir string) (AgentProcess, error) {
+	args := append([]string{"run"}, r.ExtraArgs...)
+	args = append(args, prompt)
+	return startCommand(exec.Command("opencode", args...), dir, "")
+}
+
+// CommandRunner runs an arbitrary command. The prompt replaces any argument
+// equal to "{prompt}"; without one it is written to the command's stdin.
+type CommandRunner struct {
+	Path string
+	Args []string
+}
+
+func (r *CommandRunner) Name() string {
+	return r.Path
+}
+
+func (r *CommandRunner) Start(prompt, dir string) (AgentProcess, error) {
+	args := make([]string, len(r.Args))
+	onArgv := false
+	for i, arg := range r.Args {
+		if arg == "{prompt}" {
+			arg = prompt
+			onArgv = true
+		}
+		args[i] = arg
+	}
+
+	stdin := prompt
+	if onArgv {
+		stdin = ""
+	}
+	return startCommand(exec.Command(r.Path, args...), dir, stdin)
+}
+
+// execProcess adapts an *exec.Cmd to AgentProcess
+type execProcess struct {
+	cmd    *exec.Cmd
+	stdout io.Reader
+	stderr io.Reader
+}
+
+func startCommand(cmd *exec.Cmd, dir, stdin string) (*execProcess, error) {
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &execProcess{cmd: cmd, stdout: stdout, stderr: stderr}, nil
+}
+
+func (p *execProcess) Stdout() io.Reader { return p.stdout }
+func (p *execProcess) Stderr() io.Reader { return p.stderr }
+
+func (p *execProcess) Wait() (int, error) {
+	err := p.cmd.Wait()
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 1, err
+}
+
+// Kill kills the agent's whole process group
+func (p *execProcess) Kill() error {
+	if p.cmd.Process == nil {
+		return nil
+	}
+	return killProcessGroup(p.cmd)
+}
+
+func (p *execProcess) Suspend() error {
+	return suspendProcessGroup(p.cmd)
+}
+
+func (p *execProcess) Resume() error {
+	return resumeProcessGroup(p.cmd)
+}
+
+// Terminate sends SIGTERM to the agent's process group and SIGKILL after
+// grace. The group is killed even when the agent itself has exited by then,
+// so children that ignored SIGTERM do not outlive it.
+func (p *execProcess) Terminate(grace time.Duration) error {
+	if p.cmd.Process == nil {
+		return nil
+	}
+	if err := terminateProcessGroup(p.cmd); err != nil {
+		return p.Kill()
+	}
+	time.AfterFunc(grace, func() { p.Kill() })
+	return nil
+}
+
+// ScriptedRunner replays a transcript instead of running a real agent. The
+// script holds one block per iteration, separated by "---" lines; once the
+// blocks run out the last one is repeated. Inside a block every line is
+// echoed to stdout except for these directives:
+//
+//	!sleep <duration>   pause before the next line
+//	!stderr <text>      write text to stderr
+//	!exit <code>        stop with the given exit code
+type ScriptedRunner struct {
+	path   string
+	blocks [][]string
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewScriptedRunner loads a script file for a ScriptedRunner
+func NewScriptedRunner(path string) (*ScriptedRunner, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	blocks := [][]string{{}}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "---" {
+			blocks = append(blocks, []string{})
+			continue
+		}
+		blocks[len(blocks)-1] = append(blocks[len(blocks)-1], line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &ScriptedRunner{path: path, blocks: blocks}, nil
+}
+
+func (r *ScriptedRunner) Name() string {
+	return "fake"
+}
+
+func (r *ScriptedRunner) Start(prompt, dir string) (AgentProcess, error) {
+	r.mu.Lock()
+	block := r.blocks[min(r.next, len(r.blocks)-1)]
+	r.next++
+	r.mu.Unlock()
+
+	p := newScriptedProcess()
+	go p.run(block)
+	return p, nil
+}
+
+type scriptedProcess struct {
+	stdoutR, stderrR *io.PipeReader
+	stdoutW, stderrW *io.PipeWriter
+
+	killed   chan struct{}
+	killOnce sync.Once
+	done     chan struct{}
+	exitCode int
+
+	// gate is held while suspended; run takes it before every line
+	gate      sync.Mutex
+	suspendMu sync.Mutex
+	suspended bool
+}
+
+func newScriptedProcess() *scriptedProcess {
+	p := &scriptedProcess{
+		killed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	p.stdoutR, p.stdoutW = io.Pipe()
+	p.stderrR, p.stderrW = io.Pipe()
+	return p
+}
+
+func (p *scriptedProcess) run(lines []string) {
+	defer close(p.done)
+	defer p.stdoutW.Close()
+	defer p.stderrW.Close()
+
+	for _, line := range lines {
+		p.gate.Lock()
+		p.gate.Unlock()
+
+		select {
+		case <-p.killed:
+			p.exitCode = -1
+			return
+		default:
+		}
+
+		directive, arg, _ := strings.Cut(line, " ")
+		switch directive {
+		case "!sleep":
+			d, err := time.ParseDuration(strings.TrimSpace(arg))
+			if err != nil {
+				continue
+			}
+			select {
+			case <-time.After(d):
+			case <-p.killed:
+				p.exitCode = -1
+				return
+			}
+		case "!stderr":
+			fmt.Fprintln(p.stderrW, arg)
+		case "!exit":
+			p.exitCode, _ = strconv.Atoi(strings.TrimSpace(arg))
+			return
+		default:
+			fmt.Fprintln(p.stdoutW, line)
+		}
+	}
+}
+
+func (p *scriptedProcess) Stdout() io.Reader { return p.stdoutR }
+func (p *scriptedProcess) Stderr() io.Reader { return p.stderrR }
+
+func (p *scriptedProcess) Wait() (int, error) {
+	<-p.done
+	return p.exitCode, nil
+}
+
+// Kill resumes a suspended script too, as SIGKILL ends a stopped process
+func (p *scriptedProcess) Kill() error {
+	p.killOnce.Do(func() { close(p.killed) })
+	return p.Resume()
+}
+
+func (p *scriptedProcess) Terminate(grace time.Duration) error {
+	return p.Kill()
+}
+
+func (p *scriptedProcess) Suspend() error {
+	p.suspendMu.Lock()
+	defer p.suspendMu.Unlock()
+	select {
+	case <-p.killed:
+		return nil
+	default:
+	}
+	if !p.suspended {
+		p.gate.Lock()
+		p.suspended = true
+	}
+	return nil
+}
+
+func (p *scriptedProcess) Resume() error {
+	p.suspendMu.Lock()
+	defer p.suspendMu.Unlock()
+	if p.suspended {
+		p.gate.Unlock()
+		p.suspended = false
+	}
+	return nil
+}
+
+// splitCommandLine splits s on whitespace, honouring single and double quotes
+func splitCommandLine(s string) []string {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeScript writes a ScriptedRunner transcript and loads it
+func writeScript(t *testing.T, script string) *ScriptedRunner {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.txt")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runner, err := NewScriptedRunner(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runner
+}
+
+func TestScriptedProcessKillWhileSuspended(t *testing.T) {
+	runner := writeScript(t, "!sleep 50ms\nnever printed\n")
+	proc, err := runner.Start("", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go io.Copy(io.Discard, proc.Stdout())
+	go io.Copy(io.Discard, proc.Stderr())
+
+	if err := proc.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := proc.Kill(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan int)
+	go func() {
+		code, _ := proc.Wait()
+		done <- code
+	}()
+	select {
+	case code := <-done:
+		if code != -1 {
+			t.Errorf("exit code = %d, want -1 for a killed script", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Kill did not stop a suspended script")
+	}
+}
+
+func TestScriptedRunnerRepeatsLastBlock(t *testing.T) {
+	runner := writeScript(t, "first\n---\nsecond\n!exit 3\n")
+	for i, want := range []int{0, 3, 3} {
+		proc, err := runner.Start("", t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		go io.Copy(io.Discard, proc.Stderr())
+		io.Copy(io.Discard, proc.Stdout())
+		if code, _ := proc.Wait(); code != want {
+			t.Errorf("run %d: exit code = %d, want %d", i+1, code, want)
+		}
+	}
+}
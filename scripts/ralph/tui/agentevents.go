@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AgentEventKind classifies a line of agent output
+type AgentEventKind int
+
+const (
+	AgentText AgentEventKind = iota
+	AgentToolCall
+	AgentFileEdit
+	AgentShell
+	AgentUsage
+	AgentError
+)
+
+// String names the kind in headless events
+func (k AgentEventKind) String() string {
+	switch k {
+	case AgentToolCall:
+		return "tool"
+	case AgentFileEdit:
+		return "edit"
+	case AgentShell:
+		return "shell"
+	case AgentUsage:
+		return "usage"
+	case AgentError:
+		return "error"
+	default:
+		return "text"
+	}
+}
+
+// maxToolDetail caps the tool output kept for an expanded tool-call block
+const maxToolDetail = 200
+
+// AgentEvent is a line of agent output as the loop understands it
+type AgentEvent struct {
+	Kind AgentEventKind
+	// Text is what the Output panel shows; it is empty for events that are
+	// tracked but not shown, such as token usage
+	Text string
+	// Tool names the tool of a tool call; Path is the file an edit touched
+	// and Command the command a shell call ran
+	Tool    string
+	Path    string
+	Command string
+	Failed  bool
+	// Detail is a tool call's output, shown while tool blocks are expanded
+	Detail []string
+	Usage  *TokenUsage
+	// Complete is set when the agent signals that every story passes
+	Complete bool
+}
+
+// opencodeEvent is one line of `opencode run --format json`, or the result
+// line of `claude -p --output-format json`
+type opencodeEvent struct {
+	Type string `json:"type"`
+	Part struct {
+		Type  string `json:"type"`
+		Text  string `json:"text"`
+		Tool  string `json:"tool"`
+		State struct {
+			Status string         `json:"status"`
+			Input  map[string]any `json:"input"`
+			Output string         `json:"output"`
+			Title  string         `json:"title"`
+			Error  string         `json:"error"`
+		} `json:"state"`
+		Cost   float64 `json:"cost"`
+		Tokens *struct {
+			Input     int `json:"input"`
+			Output    int `json:"output"`
+			Reasoning int `json:"reasoning"`
+			Cache     struct {
+				Read  int `json:"read"`
+				Write int `json:"write"`
+			} `json:"cache"`
+		} `json:"tokens"`
+	} `json:"part"`
+	Error *struct {
+		Name string `json:"name"`
+		Data struct {
+			Message string `json:"message"`
+		} `json:"data"`
+	} `json:"error"`
+
+	// claude's result line reports the whole session's usage
+	Result       string  `json:"result"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	Usage        *struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	} `json:"usage"`
+}
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	// opencode's plain output prints tool calls as "|  Bash     go test":
+	// the tool, two or more spaces and its title. A markdown table row has a
+	// cell after the first "|" and ends in one, so it does not match.
+	plainToolLine = regexp.MustCompile(`^\|\s+(\w+)\s{2,}([^|\s](?:.*[^|\s])?)\s*$`)
+)
+
+// ParseAgentLine turns a line of agent output into an event. Lines of
+// opencode's JSON output are decoded; anything else is plain text, where
+// regexes pick out tool calls and the completion signal. Only the agent's
+// own text can carry the signal, never a tool call.
+func ParseAgentLine(line string) AgentEvent {
+	if strings.HasPrefix(line, "{") {
+		var raw opencodeEvent
+		if json.Unmarshal([]byte(line), &raw) == nil && raw.Type != "" {
+			return opencodeAgentEvent(raw, line)
+		}
+	}
+
+	plain := ansiEscape.ReplaceAllString(line, "")
+	if m := plainToolLine.FindStringSubmatch(plain); m != nil {
+		ev := toolEvent(strings.ToLower(m[1]), strings.TrimSpace(m[2]), nil)
+		ev.Text = line
+		return ev
+	}
+	ev := AgentEvent{Kind: AgentText, Text: line}
+	ev.Complete = checkCompleteSignal(plain)
+	return ev
+}
+
+// opencodeAgentEvent maps a decoded opencode event; line is kept as the text
+// of event types it does not know
+func opencodeAgentEvent(raw opencodeEvent, line string) AgentEvent {
+	part := raw.Part
+	var ev AgentEvent
+
+	switch raw.Type {
+	case "text":
+		ev = AgentEvent{Kind: AgentText, Text: strings.TrimRight(part.Text, "\n")}
+		ev.Complete = checkCompleteSignal(part.Text)
+
+	case "tool_use":
+		// a tool call that only mentions the signal, like a grep for it, is
+		// not the agent signalling
+		state := part.State
+		ev = toolEvent(part.Tool, state.Title, state.Input)
+		if state.Output != "" {
+			ev.Detail = strings.Split(strings.TrimRight(state.Output, "\n"), "\n")
+		}
+		if state.Status == "error" {
+			ev.Failed = true
+			ev.Text += " ✗"
+			ev.Detail = append(ev.Detail, strings.Split(state.Error, "\n")...)
+		}
+		if len(ev.Detail) > maxToolDetail {
+			cut := len(ev.Detail) - maxToolDetail
+			ev.Detail = append([]string{fmt.Sprintf("… %d earlier lines", cut)}, ev.Detail[cut:]...)
+		}
+
+	case "step_finish":
+		ev = AgentEvent{Kind: AgentUsage}
+		if t := part.Tokens; t != nil {
+			ev.Usage = &TokenUsage{
+				Input:      t.Input,
+				Output:     t.Output,
+				Reasoning:  t.Reasoning,
+				CacheRead:  t.Cache.Read,
+				CacheWrite: t.Cache.Write,
+				Cost:       part.Cost,
+			}
+		}
+
+	case "step_start":
+		// nothing to show; the step's tool calls and text follow
+
+	case "result":
+		ev = AgentEvent{Kind: AgentUsage, Text: strings.TrimRight(raw.Result, "\n")}
+		ev.Complete = checkCompleteSignal(raw.Result)
+		if u := raw.Usage; u != nil || raw.TotalCostUSD > 0 {
+			ev.Usage = &TokenUsage{Cost: raw.TotalCostUSD}
+			if u != nil {
+				ev.Usage.Input = u.InputTokens
+				ev.Usage.Output = u.OutputTokens
+				ev.Usage.CacheRead = u.CacheReadInputTokens
+				ev.Usage.CacheWrite = u.CacheCreationInputTokens
+			}
+		}
+
+	case "error":
+		msg := "agent error"
+		if raw.Error != nil {
+			msg = raw.Error.Name
+			if raw.Error.Data.Message != "" {
+				msg += ": " + raw.Error.Data.Message
+			}
+		}
+		ev = AgentEvent{Kind: AgentError, Text: "✗ " + msg, Failed: true}
+
+	default:
+		ev = AgentEvent{Kind: AgentText, Text: line}
+	}
+	return ev
+}
+
+// toolEvent describes a tool call from its name, title and input, as far as
+// they are known
+func toolEvent(tool, title string, input map[string]any) AgentEvent {
+	str := func(key string) string {
+		s, _ := input[key].(string)
+		return s
+	}
+
+	ev := AgentEvent{Kind: AgentToolCall, Tool: tool}
+	switch tool {
+	case "bash", "shell":
+		ev.Kind = AgentShell
+		ev.Command = str("command")
+		if ev.Command == "" {
+			ev.Command = title
+		}
+		ev.Text = "$ " + ev.Command
+	case "edit", "write", "patch", "multiedit":
+		ev.Kind = AgentFileEdit
+		ev.Path = str("filePath")
+		if ev.Path == "" {
+			ev.Path = title
+		}
+		ev.Text = "✎ " + tool + " " + ev.Path
+	default:
+		summary := title
+		if summary == "" {
+			for _, key := range []string{"filePath", "pattern", "path", "url", "description"} {
+				if summary = str(key); summary != "" {
+					break
+				}
+			}
+		}
+		ev.Text = "⚙ " + tool + " " + summary
+	}
+	ev.Text = strings.TrimSpace(ev.Text)
+	return ev
+}
+
+// outputEvent is the headless event for a line of agent output
+func outputEvent(msg OutputLineMsg, iteration int, storyID string) Event {
+	ev := Event{
+		Type:      EventOutput,
+		Time:      msg.Timestamp,
+		Iteration: iteration,
+		StoryID:   storyID,
+		Worker:    msg.Worker,
+		Line:      msg.Line,
+		Tool:      msg.Event.Tool,
+		Path:      msg.Event.Path,
+		Command:   msg.Event.Command,
+	}
+	if msg.Event.Kind != AgentText {
+		ev.Kind = msg.Event.Kind.String()
+	}
+	return ev
+}
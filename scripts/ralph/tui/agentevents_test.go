@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestParseAgentLineCompletionSignal(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		line     string
+		complete bool
+	}{
+		{"plain text", "All done. <promise>COMPLETE</promise>", true},
+		{"opencode text", `{"type":"text","part":{"text":"<promise>COMPLETE</promise>\n"}}`, true},
+		{"claude result", `{"type":"result","result":"<promise>COMPLETE</promise>"}`, true},
+		{"plain tool call", "|  Bash     grep -r '<promise>COMPLETE</promise>' .", false},
+		{"markdown table", "| Status | <promise>COMPLETE</promise> |", true},
+		{"aligned markdown table", "| Story   | <promise>COMPLETE</promise>   |", true},
+		{"opencode tool call", `{"type":"tool_use","part":{"tool":"bash","state":{"status":"completed","input":{"command":"grep -r '<promise>COMPLETE</promise>' ."},"output":"prompt.md: <promise>COMPLETE</promise>"}}}`, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if ev := ParseAgentLine(tc.line); ev.Complete != tc.complete {
+				t.Errorf("Complete = %v, want %v for %+v", ev.Complete, tc.complete, ev)
+			}
+		})
+	}
+}
+
+func TestParseAgentLineToolLines(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		kind AgentEventKind
+	}{
+		{"|  Bash     go test ./...", AgentShell},
+		{"|  Bash     ls | wc -l", AgentShell},
+		{"|  Edit     src/app.ts", AgentFileEdit},
+		{"| Name | Value |", AgentText},
+		{"| Name   | Value   |", AgentText},
+		{"| a  b |", AgentText},
+		{"|---|---|", AgentText},
+	} {
+		if ev := ParseAgentLine(tc.line); ev.Kind != tc.kind {
+			t.Errorf("%q parsed as %v, want %v", tc.line, ev.Kind, tc.kind)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	progressFileName   = "progress.txt"
+	lastBranchFileName = ".last-branch"
+	archiveDirName     = "archive"
+)
+
+// BranchChange is detected when the PRD's branchName differs from the
+// branch recorded by the previous run
+type BranchChange struct {
+	Previous string
+	Current  string
+}
+
+// ArchivedRun is a previous run stored under archive/
+type ArchivedRun struct {
+	Name     string
+	Path     string
+	PRD      PRD
+	PRDErr   error
+	Progress string
+}
+
+// DetectBranchChange compares the PRD's branch to .last-branch in ralphDir.
+// Like ralph.sh it only reports a change when both are known.
+func DetectBranchChange(ralphDir string, prd PRD) *BranchChange {
+	data, err := os.ReadFile(filepath.Join(ralphDir, lastBranchFileName))
+	if err != nil {
+		return nil
+	}
+
+	last := strings.TrimSpace(string(data))
+	if last == "" || prd.BranchName == "" || last == prd.BranchName {
+		return nil
+	}
+	return &BranchChange{Previous: last, Current: prd.BranchName}
+}
+
+// ArchiveRun copies the PRD and progress.txt next to it into
+// archive/<date>-<branch>, dropping any "ralph/" prefix from the branch.
+// The PRD keeps its file name. It returns the archive folder.
+func ArchiveRun(prdPath, branch string, now time.Time) (string, error) {
+	ralphDir := filepath.Dir(prdPath)
+	name := strings.TrimPrefix(branch, "ralph/")
+	name = strings.ReplaceAll(name, "/", "-")
+	if name == "" {
+		name = "unnamed"
+	}
+
+	base := filepath.Join(ralphDir, archiveDirName, now.Format("2006-01-02")+"-"+name)
+	folder := base
+	for i := 2; ; i++ {
+		if _, err := os.Stat(folder); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		folder = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	if err := os.MkdirAll(folder, 0o755); err != nil {
+		return "", err
+	}
+
+	for _, file := range []string{filepath.Base(prdPath), progressFileName} {
+		data, err := os.ReadFile(filepath.Join(ralphDir, file))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(folder, file), data, 0o644)
+		}
+		if err != nil {
+			// a half-made archive would pass for a complete one
+			os.RemoveAll(folder)
+			return "", err
+		}
+	}
+
+	return folder, nil
+}
+
+// ArchivePreviousRun archives the run for archiveBranch with its history,
+// resets progress.txt and records currentBranch as the branch of the new
+// run. It returns the archive folder, also when a later step fails.
+func ArchivePreviousRun(prdPath, progressPath, archiveBranch, currentBranch string, now time.Time) (string, error) {
+	ralphDir := filepath.Dir(prdPath)
+	folder, err := ArchiveRun(prdPath, archiveBranch, now)
+	if err != nil {
+		return "", err
+	}
+	if err := ArchiveHistory(historyPath(ralphDir), archiveBranch, folder); err != nil {
+		return folder, err
+	}
+	if err := ResetProgress(progressPath, now); err != nil {
+		return folder, err
+	}
+	return folder, WriteLastBranch(ralphDir, currentBranch)
+}
+
+// ResetProgress starts a fresh progress log
+func ResetProgress(path string, now time.Time) error {
+	header := fmt.Sprintf("# Ralph Progress Log\nStarted: %s\n---\n", now.Format(time.UnixDate))
+	return os.WriteFile(path, []byte(header), 0o644)
+}
+
+// EnsureProgress creates the progress log if it does not exist yet
+func EnsureProgress(path string, now time.Time) error {
+	if _, err := os.Stat(path); err == nil || !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return ResetProgress(path, now)
+}
+
+// WriteLastBranch records the branch of the current run
+func WriteLastBranch(ralphDir, branch string) error {
+	if branch == "" {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(ralphDir, lastBranchFileName), []byte(branch+"\n"), 0o644)
+}
+
+// ListArchives returns the runs archived next to the PRD, newest first
+func ListArchives(prdPath string) ([]ArchivedRun, error) {
+	dir := filepath.Join(filepath.Dir(prdPath), archiveDirName)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []ArchivedRun
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		run := ArchivedRun{Name: entry.Name(), Path: filepath.Join(dir, entry.Name())}
+		run.PRD, run.PRDErr = LoadPRD(filepath.Join(run.Path, filepath.Base(prdPath)))
+		if data, err := os.ReadFile(filepath.Join(run.Path, progressFileName)); err == nil {
+			run.Progress = string(data)
+		}
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Name > runs[j].Name
+	})
+
+	return runs, nil
+}
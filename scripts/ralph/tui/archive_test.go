@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestArchiveRunKeepsPRDName(t *testing.T) {
+	prdPath := filepath.Join(t.TempDir(), "backlog.json")
+	if err := os.WriteFile(prdPath, []byte(`{"project": "test", "branchName": "ralph/old", "userStories": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	folder, err := ArchiveRun(prdPath, "ralph/old", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "backlog.json")); err != nil {
+		t.Errorf("archived PRD: %v", err)
+	}
+
+	runs, err := ListArchives(prdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].PRDErr != nil || runs[0].PRD.BranchName != "ralph/old" {
+		t.Errorf("archived runs = %+v", runs)
+	}
+}
+
+func TestArchiveConfirmArchivesPreviousRun(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1))
+	ralphDir := filepath.Dir(prdPath)
+	progress := filepath.Join(ralphDir, progressFileName)
+	for path, content := range map[string]string{
+		filepath.Join(ralphDir, lastBranchFileName): "ralph/old\n",
+		progress: "old learnings\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := newTestModel(t, Config{PRD: prdPath}, "done\n")
+	if m.overlay != OverlayArchiveConfirm {
+		t.Fatalf("branch change was not detected, overlay = %v", m.overlay)
+	}
+	_, cmd := m.updateArchiveConfirm(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	msg, ok := cmd().(RunArchivedMsg)
+	if !ok || msg.Err != nil {
+		t.Fatalf("archive failed: %+v", msg)
+	}
+
+	for file, want := range map[string]string{progressFileName: "old learnings\n", "prd.json": ""} {
+		data, err := os.ReadFile(filepath.Join(msg.Folder, file))
+		if err != nil {
+			t.Errorf("archived %s: %v", file, err)
+		} else if want != "" && string(data) != want {
+			t.Errorf("archived %s = %q, want %q", file, data, want)
+		}
+	}
+	if data, _ := os.ReadFile(progress); strings.Contains(string(data), "old learnings") {
+		t.Errorf("progress.txt was not reset: %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ralphDir, lastBranchFileName)); string(data) != testBranch+"\n" {
+		t.Errorf(".last-branch = %q, want %q", data, testBranch)
+	}
+}
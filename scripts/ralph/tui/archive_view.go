@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const archiveListWidth = 40
+
+func (m Model) updateArchiveConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	change := m.pendingArchive
+
+	switch msg.String() {
+	case "y", "Y":
+		m.overlay = OverlayNone
+		return m, archiveRunCmd(m.prdPath, m.progressPath, change.Previous, change.Current)
+
+	case "n", "N", "esc":
+		m.overlay = OverlayNone
+		m.pendingArchive = nil
+		if change.Previous == change.Current {
+			return m, nil
+		}
+		// Keep the old progress log but treat the new branch as this run's
+		cmds := []tea.Cmd{bootstrapRunCmd(m.ralphDir, m.progressPath, change.Current)}
+		if m.autoStart {
+			cmds = append(cmds, func() tea.Msg { return AutoStartMsg{} })
+		}
+		return m, tea.Batch(cmds...)
+
+	case "ctrl+c":
+		return m, m.requestQuit()
+	}
+
+	return m, nil
+}
+
+func (m Model) updateArchiveBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "B":
+		m.overlay = OverlayNone
+	case "ctrl+c":
+		return m, m.requestQuit()
+	case "up", "k":
+		m.showArchive(m.archiveCursor - 1)
+	case "down", "j":
+		m.showArchive(m.archiveCursor + 1)
+	case "K":
+		m.archiveViewport.LineUp(1)
+	case "J":
+		m.archiveViewport.LineDown(1)
+	case "pgup", "ctrl+u":
+		m.archiveViewport.HalfViewUp()
+	case "pgdown", "ctrl+d":
+		m.archiveViewport.HalfViewDown()
+	}
+	return m, nil
+}
+
+// showArchive selects an archived run and loads its details
+func (m *Model) showArchive(idx int) {
+	if idx < 0 || idx >= len(m.archives) {
+		if len(m.archives) > 0 {
+			return
+		}
+		idx = 0
+	}
+	m.archiveCursor = idx
+	m.resizeArchiveViewport()
+
+	if len(m.archives) == 0 {
+		m.archiveViewport.SetContent(HelpStyle.Render("No archived runs in " + m.ralphDir + "/" + archiveDirName))
+		return
+	}
+	m.archiveViewport.SetContent(renderArchiveDetail(m.archives[idx]))
+	m.archiveViewport.GotoTop()
+}
+
+func (m *Model) resizeArchiveViewport() {
+	m.archiveViewport.Width = max(20, m.width-archiveListWidth-12)
+	m.archiveViewport.Height = max(5, m.height-8)
+}
+
+func (m Model) renderArchiveConfirm() string {
+	change := m.pendingArchive
+
+	var question string
+	if change.Previous != change.Current {
+		question = fmt.Sprintf("The PRD branch changed from %s to %s.\n\nArchive the previous run and reset progress.txt?",
+			TitleStyle.Render(change.Previous), TitleStyle.Render(change.Current))
+	} else {
+		question = fmt.Sprintf("Archive the current run (%s) and reset progress.txt?", TitleStyle.Render(change.Current))
+	}
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(Purple).
+		Padding(1, 3).
+		Render(lipgloss.JoinVertical(lipgloss.Left,
+			HeaderStyle.Render(" Archive Run "),
+			"",
+			question,
+			"",
+			HelpStyle.Render("y: archive and reset │ n/esc: keep as is"),
+		))
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
+
+func (m Model) renderArchiveBrowser() string {
+	panelHeight := max(minPanelHeight, m.height-6)
+
+	var lines []string
+	for i, run := range m.archives {
+		summary := "?"
+		if run.PRDErr == nil {
+			summary = fmt.Sprintf("%d/%d", CountCompleted(run.PRD.UserStories), len(run.PRD.UserStories))
+		}
+		name := run.Name
+		if len(name) > archiveListWidth-10 {
+			name = name[:archiveListWidth-13] + "..."
+		}
+
+		line := fmt.Sprintf("%-*s %s", archiveListWidth-10, name, summary)
+		if i == m.archiveCursor {
+			line = StoryCurrentStyle.Render(CurrentIcon + " " + line)
+		} else {
+			line = StoryPendingStyle.Render("  " + line)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, HelpStyle.Render("(none)"))
+	}
+
+	list := PanelActiveStyle.Width(archiveListWidth).Height(panelHeight).Render(
+		lipgloss.JoinVertical(lipgloss.Left, PanelTitleStyle.Render("Archived Runs"), strings.Join(lines, "\n")))
+	detail := PanelStyle.Width(m.archiveViewport.Width + 2).Height(panelHeight).Render(m.archiveViewport.View())
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		HeaderStyle.Render(" Ralph - Archive "),
+		lipgloss.JoinHorizontal(lipgloss.Top, list, detail),
+		HelpStyle.Render("↑/↓ select │ J/K or PgUp/PgDn scroll │ esc close"),
+	)
+}
+
+func renderArchiveDetail(run ArchivedRun) string {
+	lines := []string{TitleStyle.Render(run.Name), HelpStyle.Render(run.Path), ""}
+
+	if run.PRDErr != nil {
+		lines = append(lines, lipgloss.NewStyle().Foreground(Red).Render("PRD: "+run.PRDErr.Error()))
+	} else {
+		prd := run.PRD
+		lines = append(lines, fmt.Sprintf("%s (%s)", prd.Project, prd.BranchName))
+		if prd.Description != "" {
+			lines = append(lines, HelpStyle.Render(prd.Description))
+		}
+		lines = append(lines, "")
+		for _, story := range prd.UserStories {
+			icon := PendingIcon
+			if story.Passes {
+				icon = SuccessIcon
+			}
+			lines = append(lines, fmt.Sprintf("%s %s %s", icon, story.ID, story.Title))
+		}
+	}
+
+	if run.Progress != "" {
+		lines = append(lines, "", PanelTitleStyle.Render("progress.txt"), LogTextStyle.Render(run.Progress))
+	}
+
+	return strings.Join(lines, "\n")
+}
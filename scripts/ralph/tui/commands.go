@@ -5,13 +5,10 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"regexp"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/fsnotify/fsnotify"
 )
 
 type PRDUpdatedMsg struct {
@@ -19,127 +16,158 @@ type PRDUpdatedMsg struct {
 	Err error
 }
 
+// Worker identifies the parallel worker a message belongs to; 0 is the
+// sequential loop. Line is the text to show, which is empty for events that
+// are only tracked.
 type OutputLineMsg struct {
+	Worker    int
 	Line      string
+	Event     AgentEvent
 	Timestamp time.Time
 }
 
 type ProcessStartedMsg struct {
+	Worker    int
 	Iteration int
 	StoryID   string
-	Cmd       *exec.Cmd
+	Proc      AgentProcess
 }
 
 type ProcessExitedMsg struct {
-	ExitCode int
-	Complete bool
-	Err      error
+	Worker    int
+	Iteration int
+	StoryID   string
+	ExitCode  int
+	Err       error
+	Start     time.Time
+	End       time.Time
+	// CommitSHA is HEAD after the iteration when the agent committed
+	CommitSHA string
+	// Commits lists every commit the iteration added, oldest first
+	Commits []Commit
+	LogPath string
+	// PRD is reloaded once the agent exits so the loop never acts on a
+	// stale copy while the file watcher catches up
+	PRD *PRD
+	// Verification is set when checks ran after the iteration
+	Verification *Verification
+	// RolledBack is set when the working tree was reset after a failure;
+	// PatchPath then holds the discarded changes, if there were any
+	RolledBack bool
+	PatchPath  string
+	// MergeErr is set when a parallel worker's story passed but could not be
+	// merged back into the PRD branch
+	MergeErr string
 }
 
 type TickMsg time.Time
 
+// AutoStartMsg starts the first iteration without waiting for 'r'
+type AutoStartMsg struct{}
+
 type ErrorMsg struct {
 	Err error
 }
 
-func watchPRDCmd(path string) tea.Cmd {
-	return func() tea.Msg {
-		watcher, err := fsnotify.NewWatcher()
-		if err != nil {
-			return ErrorMsg{Err: err}
-		}
-		defer watcher.Close()
+type RunArchivedMsg struct {
+	Folder string
+	Err    error
+}
 
-		if err := watcher.Add(path); err != nil {
-			return ErrorMsg{Err: err}
-		}
+type LogLoadedMsg struct {
+	Path  string
+	Lines []string
+	Err   error
+}
 
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return nil
-				}
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					prd, err := LoadPRD(path)
-					return PRDUpdatedMsg{PRD: prd, Err: err}
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return nil
-				}
-				return ErrorMsg{Err: err}
-			}
-		}
-	}
+type ArchivesLoadedMsg struct {
+	Runs []ArchivedRun
+	Err  error
 }
 
-func runIterationCmd(promptPath, projectRoot string, iteration int, storyID string, msgChan chan<- interface{}) tea.Cmd {
-	return func() tea.Msg {
-		promptContent, err := os.ReadFile(promptPath)
-		if err != nil {
-			return ProcessExitedMsg{ExitCode: 1, Complete: false, Err: err}
+// streamOutput parses each line from reader into an event for msgChan and,
+// when log is set, writes the raw line to the iteration's log file tagged
+// with stream. It reads reader to the end, even past a line it cannot take.
+func streamOutput(reader io.Reader, worker int, stream string, log *iterationLog, msgChan chan<- interface{}) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		now := time.Now()
+		log.WriteLine(now, stream, line)
+		ev := ParseAgentLine(line)
+		msgChan <- OutputLineMsg{
+			Worker:    worker,
+			Line:      ev.Text,
+			Event:     ev,
+			Timestamp: now,
 		}
+	}
 
-		cmd := exec.Command("opencode", "run", string(promptContent))
-		cmd.Dir = projectRoot
-
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			return ProcessExitedMsg{ExitCode: 1, Complete: false, Err: err}
-		}
+	// A line too long for the buffer ends the scan; the rest is drained so
+	// the agent does not block on a full pipe
+	if err := scanner.Err(); err != nil {
+		now := time.Now()
+		line := "✗ output unreadable from here on: " + err.Error()
+		log.WriteLine(now, stream, line)
+		msgChan <- OutputLineMsg{Worker: worker, Line: line, Event: AgentEvent{Kind: AgentError, Text: line, Failed: true}, Timestamp: now}
+		io.Copy(io.Discard, reader)
+	}
+}
 
-		stderr, err := cmd.StderrPipe()
-		if err != nil {
-			return ProcessExitedMsg{ExitCode: 1, Complete: false, Err: err}
-		}
+func listenForOutputCmd(msgChan <-chan interface{}) tea.Cmd {
+	return func() tea.Msg {
+		msg := <-msgChan
+		return msg
+	}
+}
 
-		if err := cmd.Start(); err != nil {
-			return ProcessExitedMsg{ExitCode: 1, Complete: false, Err: err}
+// bootstrapRunCmd seeds progress.txt and records the current branch
+func bootstrapRunCmd(ralphDir, progressPath, branch string) tea.Cmd {
+	return func() tea.Msg {
+		if err := EnsureProgress(progressPath, time.Now()); err != nil {
+			return ErrorMsg{Err: err}
 		}
-
-		msgChan <- ProcessStartedMsg{
-			Iteration: iteration,
-			StoryID:   storyID,
-			Cmd:       cmd,
+		if err := WriteLastBranch(ralphDir, branch); err != nil {
+			return ErrorMsg{Err: err}
 		}
+		return nil
+	}
+}
 
-		go streamOutput(stdout, msgChan)
-		go streamOutput(stderr, msgChan)
-
-		err = cmd.Wait()
-		exitCode := 0
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				exitCode = exitErr.ExitCode()
-			} else {
-				exitCode = 1
-			}
-		}
+// archiveRunCmd archives the run for archiveBranch and starts the run for
+// currentBranch
+func archiveRunCmd(prdPath, progressPath, archiveBranch, currentBranch string) tea.Cmd {
+	return func() tea.Msg {
+		folder, err := ArchivePreviousRun(prdPath, progressPath, archiveBranch, currentBranch, time.Now())
+		return RunArchivedMsg{Folder: folder, Err: err}
+	}
+}
 
-		return ProcessExitedMsg{
-			ExitCode: exitCode,
-			Complete: false,
-			Err:      nil,
+// appendHistoryCmd persists an iteration record
+func appendHistoryCmd(path string, rec IterationRecord) tea.Cmd {
+	return func() tea.Msg {
+		if err := AppendHistory(path, rec); err != nil {
+			return ErrorMsg{Err: err}
 		}
+		return nil
 	}
 }
 
-func streamOutput(reader io.Reader, msgChan chan<- interface{}) {
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		line := scanner.Text()
-		msgChan <- OutputLineMsg{
-			Line:      line,
-			Timestamp: time.Now(),
-		}
+func loadArchivesCmd(prdPath string) tea.Cmd {
+	return func() tea.Msg {
+		runs, err := ListArchives(prdPath)
+		return ArchivesLoadedMsg{Runs: runs, Err: err}
 	}
 }
 
-func listenForOutputCmd(msgChan <-chan interface{}) tea.Cmd {
+func loadLogCmd(path string) tea.Cmd {
 	return func() tea.Msg {
-		msg := <-msgChan
-		return msg
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return LogLoadedMsg{Path: path, Err: err}
+		}
+		return LogLoadedMsg{Path: path, Lines: strings.Split(strings.TrimRight(string(data), "\n"), "\n")}
 	}
 }
 
@@ -149,22 +177,6 @@ func tickCmd() tea.Cmd {
 	})
 }
 
-var storyPatterns = []*regexp.Regexp{
-	regexp.MustCompile(`Working on (S\d+)`),
-	regexp.MustCompile(`Next story: (S\d+)`),
-	regexp.MustCompile(`feat: (S\d+)`),
-	regexp.MustCompile(`\[(S\d+)\]`),
-}
-
-func parseStoryFromLine(line string) (storyID string, found bool) {
-	for _, pattern := range storyPatterns {
-		if matches := pattern.FindStringSubmatch(line); len(matches) > 1 {
-			return matches[1], true
-		}
-	}
-	return "", false
-}
-
 func checkCompleteSignal(line string) bool {
 	return strings.Contains(line, "<promise>COMPLETE</promise>")
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamOutputDrainsPastLongLine(t *testing.T) {
+	input := "first\n" + strings.Repeat("x", 5*1024*1024) + "\nafter\n"
+	reader := strings.NewReader(input)
+	msgChan := make(chan interface{}, 10)
+
+	done := make(chan struct{})
+	go func() {
+		streamOutput(reader, 0, "", nil, msgChan)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("streamOutput did not return")
+	}
+
+	if reader.Len() != 0 {
+		t.Errorf("%d bytes left unread", reader.Len())
+	}
+	close(msgChan)
+	var lines []string
+	for msg := range msgChan {
+		lines = append(lines, msg.(OutputLineMsg).Line)
+	}
+	if len(lines) != 2 || lines[0] != "first" || !strings.Contains(lines[1], "token too long") {
+		t.Errorf("lines = %q, want the first line and the read error", lines)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultKillGrace = 10 * time.Second
+
+// configFileNames are searched, in order, when no --config is given
+var configFileNames = []string{"ralph.toml", "ralph.yaml", "ralph.yml"}
+
+// Config holds the settings for a run. Values are resolved with this
+// precedence, highest first: command-line flags, the config file, then
+// defaults derived from the PRD or executable location. The agent is the
+// exception: the PRD's "agent" field sits between the flag and the config file.
+type Config struct {
+	PRD           string `toml:"prd" yaml:"prd"`
+	Prompt        string `toml:"prompt" yaml:"prompt"`
+	Root          string `toml:"root" yaml:"root"`
+	MaxIterations int    `toml:"max_iterations" yaml:"max_iterations"`
+	Agent         string `toml:"agent" yaml:"agent"`
+	AutoStart     bool   `toml:"auto_start" yaml:"auto_start"`
+	Headless      bool   `toml:"headless" yaml:"headless"`
+	Format        string `toml:"format" yaml:"format"`
+
+	// IterationTimeout kills an iteration that runs longer than this
+	IterationTimeout time.Duration `toml:"iteration_timeout" yaml:"iteration_timeout"`
+	// StallTimeout kills an iteration that prints nothing for this long
+	StallTimeout time.Duration `toml:"stall_timeout" yaml:"stall_timeout"`
+	// KillGrace is how long a stopped agent gets between SIGTERM and SIGKILL
+	KillGrace time.Duration `toml:"kill_grace" yaml:"kill_grace"`
+
+	// Checks are shell commands run in the project root after an iteration
+	// that marks stories as passing; if one fails the stories are reverted
+	Checks []string `toml:"checks" yaml:"checks"`
+	// CheckTimeout limits each check command (0 disables)
+	CheckTimeout time.Duration `toml:"check_timeout" yaml:"check_timeout"`
+
+	// Rollback resets the working tree to where a failed iteration started,
+	// saving the discarded changes as a patch
+	Rollback bool `toml:"rollback" yaml:"rollback"`
+
+	// MaxAttempts is how many failed iterations a story gets before it is
+	// marked stuck and skipped (0 disables)
+	MaxAttempts int `toml:"max_attempts" yaml:"max_attempts"`
+
+	// Workers above 1 run that many stories at once, each in its own git
+	// worktree on a story branch merged into the PRD branch once it passes
+	Workers int `toml:"workers" yaml:"workers"`
+
+	// StoryPatterns are extra regexes for spotting the story the agent says
+	// it works on; each captures the ID in a group named "id" or its first
+	StoryPatterns []string `toml:"story_patterns" yaml:"story_patterns"`
+
+	// File is the config file the values were read from, if any
+	File string `toml:"-" yaml:"-"`
+}
+
+// FindConfigFile returns the first config file found in dirs, or ""
+func FindConfigFile(dirs ...string) string {
+	for _, dir := range dirs {
+		for _, name := range configFileNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// LoadConfigFile parses a TOML or YAML config file. Relative paths inside it
+// are resolved against the file's directory.
+func LoadConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		if _, err := toml.Decode(string(data), &cfg); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return Config{}, fmt.Errorf("%s: unsupported config format (want .toml, .yaml or .yml)", path)
+	}
+
+	if _, err := CompileStoryPatterns(cfg.StoryPatterns); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	base := filepath.Dir(path)
+	cfg.PRD = resolvePath(base, cfg.PRD)
+	cfg.Prompt = resolvePath(base, cfg.Prompt)
+	cfg.Root = resolvePath(base, cfg.Root)
+	cfg.File = path
+
+	return cfg, nil
+}
+
+// fillDefaults derives any unset paths. Without an explicit PRD the layout
+// next to the executable (scripts/ralph) is assumed; otherwise the prompt
+// sits next to the PRD and the root is the PRD's git repository.
+func (c *Config) fillDefaults(exeDir string) {
+	defer c.absPaths()
+
+	if c.PRD == "" {
+		c.PRD = filepath.Join(exeDir, "prd.json")
+		if c.Prompt == "" {
+			c.Prompt = filepath.Join(exeDir, "prompt.md")
+		}
+		if c.Root == "" {
+			c.Root = filepath.Dir(filepath.Dir(exeDir))
+		}
+		return
+	}
+
+	prdDir := filepath.Dir(c.PRD)
+	if c.Prompt == "" {
+		c.Prompt = filepath.Join(prdDir, "prompt.md")
+	}
+	if c.Root == "" {
+		c.Root = gitTopLevel(prdDir)
+	}
+	if c.Root == "" {
+		c.Root, _ = os.Getwd()
+	}
+}
+
+// absPaths makes the resolved paths absolute so they stay valid whatever
+// directory the agent or git runs in
+func (c *Config) absPaths() {
+	for _, p := range []*string{&c.PRD, &c.Prompt, &c.Root} {
+		if abs, err := filepath.Abs(*p); err == nil {
+			*p = abs
+		}
+	}
+}
+
+func resolvePath(base, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateDependencies checks that every dependsOn entry names another story
+// and that the dependencies contain no cycle
+func ValidateDependencies(stories []Story) error {
+	known := make(map[string]bool, len(stories))
+	for _, s := range stories {
+		known[s.ID] = true
+	}
+	for _, s := range stories {
+		for _, dep := range s.DependsOn {
+			switch {
+			case dep == s.ID:
+				return fmt.Errorf("story %s depends on itself", s.ID)
+			case !known[dep]:
+				return fmt.Errorf("story %s depends on unknown story %s", s.ID, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(stories))
+	var path []string
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case done:
+			return nil
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == id {
+					start = i
+				}
+			}
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path[start:], id), " → "))
+		}
+
+		state[id] = visiting
+		path = append(path, id)
+		for _, dep := range GetStoryByID(stories, id).DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+	for _, s := range stories {
+		if err := visit(s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmetDependencies returns the stories story waits on that do not pass yet
+func UnmetDependencies(stories []Story, story Story) []string {
+	var unmet []string
+	for _, dep := range story.DependsOn {
+		if s := GetStoryByID(stories, dep); s == nil || !s.Passes {
+			unmet = append(unmet, dep)
+		}
+	}
+	return unmet
+}
+
+// DependenciesMet reports whether every story story depends on passes
+func DependenciesMet(stories []Story, story Story) bool {
+	return len(UnmetDependencies(stories, story)) == 0
+}
+
+// storyTree orders stories as a dependency tree: each story is listed under
+// its first dependency, children in priority order. It returns the stories
+// with the depth of each.
+func storyTree(stories []Story) ([]Story, map[string]int) {
+	children := make(map[string][]Story)
+	var roots []Story
+	for _, s := range stories {
+		if len(s.DependsOn) == 0 || GetStoryByID(stories, s.DependsOn[0]) == nil {
+			roots = append(roots, s)
+			continue
+		}
+		children[s.DependsOn[0]] = append(children[s.DependsOn[0]], s)
+	}
+
+	ordered := make([]Story, 0, len(stories))
+	depths := make(map[string]int, len(stories))
+	var walk func(s Story, depth int)
+	walk = func(s Story, depth int) {
+		if _, seen := depths[s.ID]; seen {
+			return
+		}
+		ordered = append(ordered, s)
+		depths[s.ID] = depth
+		for _, child := range children[s.ID] {
+			walk(child, depth+1)
+		}
+	}
+	for _, s := range roots {
+		walk(s, 0)
+	}
+	// stories caught in a cycle are unreachable from the roots
+	for _, s := range stories {
+		walk(s, 0)
+	}
+	return ordered, depths
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DiffLoadedMsg carries the rendered commits of one story
+type DiffLoadedMsg struct {
+	StoryID string
+	Commits []Commit
+	Text    string
+	Err     error
+}
+
+// storyCommits returns the commits recorded for a story, oldest first,
+// leaving out those a rollback discarded
+func (m Model) storyCommits(storyID string) []Commit {
+	var commits []Commit
+	for _, rec := range m.history {
+		if rec.RolledBack {
+			continue
+		}
+		for _, c := range rec.Commits {
+			if c.StoryID == storyID {
+				commits = append(commits, c)
+			}
+		}
+	}
+	return commits
+}
+
+// loadDiffCmd shows the given commits; without any it falls back to the
+// commits on HEAD whose message mentions the story
+func loadDiffCmd(dir, storyID string, commits []Commit, stories []Story) tea.Cmd {
+	return func() tea.Msg {
+		if len(commits) == 0 {
+			for _, c := range commitsBetween(dir, "", "HEAD", stories, "") {
+				if c.StoryID == storyID {
+					commits = append(commits, c)
+				}
+			}
+		}
+		if len(commits) == 0 {
+			return DiffLoadedMsg{StoryID: storyID}
+		}
+
+		shas := make([]string, len(commits))
+		for i, c := range commits {
+			shas[i] = c.SHA
+		}
+		text, err := gitShow(dir, shas)
+		return DiffLoadedMsg{StoryID: storyID, Commits: commits, Text: text, Err: err}
+	}
+}
+
+func (m *Model) openDiff(story *Story) tea.Cmd {
+	m.overlay = OverlayDiff
+	m.diffStoryID = story.ID
+	m.diffCommits = nil
+	m.resizeDiffViewport()
+	m.diffViewport.SetContent(HelpStyle.Render("Loading commits for " + story.ID + "…"))
+	return loadDiffCmd(m.projectRoot, story.ID, m.storyCommits(story.ID), m.stories)
+}
+
+func (m *Model) showDiff(msg DiffLoadedMsg) {
+	if msg.StoryID != m.diffStoryID {
+		return
+	}
+	m.diffCommits = msg.Commits
+	m.diffCommitLines = nil
+	for i, line := range strings.Split(msg.Text, "\n") {
+		if strings.HasPrefix(line, "commit ") {
+			m.diffCommitLines = append(m.diffCommitLines, i)
+		}
+	}
+	switch {
+	case msg.Err != nil:
+		m.diffViewport.SetContent(lipgloss.NewStyle().Foreground(Red).Render(msg.Err.Error()))
+	case len(msg.Commits) == 0:
+		m.diffViewport.SetContent(HelpStyle.Render("No commits found for " + msg.StoryID))
+	default:
+		m.diffViewport.SetContent(colorizeDiff(msg.Text))
+	}
+	m.diffViewport.GotoTop()
+}
+
+func (m Model) updateDiffView(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "d":
+		m.overlay = OverlayNone
+	case "ctrl+c":
+		return m, m.requestQuit()
+	case "up", "k":
+		m.diffViewport.LineUp(1)
+	case "down", "j":
+		m.diffViewport.LineDown(1)
+	case "pgup", "ctrl+u":
+		m.diffViewport.HalfViewUp()
+	case "pgdown", "ctrl+d", " ":
+		m.diffViewport.HalfViewDown()
+	case "g":
+		m.diffViewport.GotoTop()
+	case "G":
+		m.diffViewport.GotoBottom()
+	case "n":
+		m.jumpDiffCommit(1)
+	case "N":
+		m.jumpDiffCommit(-1)
+	}
+	return m, nil
+}
+
+// jumpDiffCommit scrolls to the next or previous commit header
+func (m *Model) jumpDiffCommit(dir int) {
+	offset := m.diffViewport.YOffset
+	if dir > 0 {
+		for _, line := range m.diffCommitLines {
+			if line > offset {
+				m.diffViewport.SetYOffset(line)
+				return
+			}
+		}
+		return
+	}
+	for i := len(m.diffCommitLines) - 1; i >= 0; i-- {
+		if line := m.diffCommitLines[i]; line < offset {
+			m.diffViewport.SetYOffset(line)
+			return
+		}
+	}
+}
+
+func (m *Model) resizeDiffViewport() {
+	m.diffViewport.Width = max(20, m.width-4)
+	m.diffViewport.Height = max(5, m.height-6)
+}
+
+func colorizeDiff(text string) string {
+	added := lipgloss.NewStyle().Foreground(Green)
+	removed := lipgloss.NewStyle().Foreground(Red)
+	hunk := lipgloss.NewStyle().Foreground(Purple)
+	header := lipgloss.NewStyle().Foreground(Yellow).Bold(true)
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "commit "):
+			lines[i] = header.Render(line)
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = TitleStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = added.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = removed.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = hunk.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m Model) renderDiffView() string {
+	title := fmt.Sprintf(" Ralph - Diff %s ", m.diffStoryID)
+	if story := GetStoryByID(m.stories, m.diffStoryID); story != nil {
+		title = fmt.Sprintf(" Ralph - Diff %s: %s ", story.ID, story.Title)
+	}
+
+	footer := fmt.Sprintf("%d commits │ ↑/↓ scroll │ n/N next/prev commit │ esc close", len(m.diffCommits))
+	body := PanelActiveStyle.Width(m.diffViewport.Width + 2).Height(m.diffViewport.Height).Render(m.diffViewport.View())
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		HeaderStyle.Render(title),
+		body,
+		HelpStyle.Render(footer),
+	)
+}
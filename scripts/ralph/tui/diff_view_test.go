@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestStoryCommitsSkipsRolledBackIterations(t *testing.T) {
+	m := Model{history: []IterationRecord{
+		{StoryID: "US-001", Commits: []Commit{{SHA: "aaa", StoryID: "US-001"}}},
+		{StoryID: "US-001", RolledBack: true, Commits: []Commit{{SHA: "bbb", StoryID: "US-001"}}},
+		{StoryID: "US-002", Commits: []Commit{{SHA: "ccc", StoryID: "US-002"}, {SHA: "ddd", StoryID: "US-001"}}},
+	}}
+
+	var shas []string
+	for _, c := range m.storyCommits("US-001") {
+		shas = append(shas, c.SHA)
+	}
+	if len(shas) != 2 || shas[0] != "aaa" || shas[1] != "ddd" {
+		t.Errorf("commits = %v, want [aaa ddd]", shas)
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StoryEditedMsg reports the outcome of a change made from the editor
+type StoryEditedMsg struct {
+	// StoryID is the story to select once the PRD is reloaded
+	StoryID string
+	Action  string
+	PRD     PRD
+	Err     error
+}
+
+// editStoryCmd applies a prd.json edit off the UI goroutine and reloads the
+// PRD so the panels reflect it without waiting for the file watcher
+func editStoryCmd(path, storyID, action string, edit func() error) tea.Cmd {
+	return func() tea.Msg {
+		if err := edit(); err != nil {
+			return StoryEditedMsg{StoryID: storyID, Action: action, Err: err}
+		}
+		prd, err := LoadPRD(path)
+		return StoryEditedMsg{StoryID: storyID, Action: action, PRD: prd, Err: err}
+	}
+}
+
+// Form fields, in tab order
+const (
+	fieldID = iota
+	fieldTitle
+	fieldPriority
+	fieldDependsOn
+	fieldDescription
+	fieldCriteria
+	fieldNotes
+)
+
+const formLabelWidth = 12
+
+// formField is a single-line input, or a textarea when multiline is set
+type formField struct {
+	label     string
+	multiline bool
+	input     textinput.Model
+	area      textarea.Model
+}
+
+func (f formField) value() string {
+	if f.multiline {
+		return f.area.Value()
+	}
+	return f.input.Value()
+}
+
+// storyForm edits one story; originalID is empty for a new story
+type storyForm struct {
+	originalID string
+	fields     []formField
+	focus      int
+	err        string
+}
+
+func newStoryForm(originalID string, e StoryEdit) *storyForm {
+	single := func(label, value string) formField {
+		input := textinput.New()
+		input.Prompt = ""
+		input.SetValue(value)
+		return formField{label: label, input: input}
+	}
+	multi := func(label, value string) formField {
+		area := textarea.New()
+		area.ShowLineNumbers = false
+		area.CharLimit = 0
+		area.SetValue(value)
+		return formField{label: label, multiline: true, area: area}
+	}
+
+	f := &storyForm{
+		originalID: originalID,
+		fields: []formField{
+			fieldID:          single("ID", e.ID),
+			fieldTitle:       single("Title", e.Title),
+			fieldPriority:    single("Priority", strconv.Itoa(e.Priority)),
+			fieldDependsOn:   single("Depends on", strings.Join(e.DependsOn, ", ")),
+			fieldDescription: multi("Description", e.Description),
+			fieldCriteria:    multi("Acceptance criteria (one per line)", strings.Join(e.AcceptanceCriteria, "\n")),
+			fieldNotes:       multi("Notes", e.Notes),
+		},
+	}
+	f.setFocus(fieldTitle)
+	return f
+}
+
+// setFocus moves the cursor to field i, wrapping around
+func (f *storyForm) setFocus(i int) tea.Cmd {
+	n := len(f.fields)
+	f.focus = (i%n + n) % n
+	var cmd tea.Cmd
+	for i := range f.fields {
+		field := &f.fields[i]
+		switch {
+		case i != f.focus && field.multiline:
+			field.area.Blur()
+		case i != f.focus:
+			field.input.Blur()
+		case field.multiline:
+			cmd = field.area.Focus()
+		default:
+			cmd = field.input.Focus()
+		}
+	}
+	return cmd
+}
+
+// update passes a message to the focused field
+func (f *storyForm) update(msg tea.Msg) tea.Cmd {
+	field := &f.fields[f.focus]
+	var cmd tea.Cmd
+	if field.multiline {
+		field.area, cmd = field.area.Update(msg)
+	} else {
+		field.input, cmd = field.input.Update(msg)
+	}
+	return cmd
+}
+
+// resize fits the fields to the screen, sharing the spare height between
+// the textareas
+func (f *storyForm) resize(width, height int) {
+	areaHeight := max(2, (height-16)/3)
+	for i := range f.fields {
+		field := &f.fields[i]
+		if field.multiline {
+			field.area.SetWidth(max(20, width-8))
+			field.area.SetHeight(areaHeight)
+		} else {
+			field.input.Width = max(20, width-formLabelWidth-10)
+		}
+	}
+}
+
+// edit reads the form back, rejecting what the schema would refuse anyway
+// with a message naming the field
+func (f *storyForm) edit() (StoryEdit, error) {
+	e := StoryEdit{
+		ID:          strings.TrimSpace(f.fields[fieldID].value()),
+		Title:       strings.TrimSpace(f.fields[fieldTitle].value()),
+		Description: strings.TrimSpace(f.fields[fieldDescription].value()),
+		Notes:       strings.TrimSpace(f.fields[fieldNotes].value()),
+	}
+	switch {
+	case e.ID == "":
+		return e, fmt.Errorf("ID must not be empty")
+	case e.Title == "":
+		return e, fmt.Errorf("title must not be empty")
+	}
+
+	priority, err := strconv.Atoi(strings.TrimSpace(f.fields[fieldPriority].value()))
+	if err != nil || priority < 0 {
+		return e, fmt.Errorf("priority must be a whole number of 0 or more")
+	}
+	e.Priority = priority
+
+	for _, dep := range strings.Split(f.fields[fieldDependsOn].value(), ",") {
+		if dep = strings.TrimSpace(dep); dep != "" {
+			e.DependsOn = append(e.DependsOn, dep)
+		}
+	}
+	for _, line := range strings.Split(f.fields[fieldCriteria].value(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			e.AcceptanceCriteria = append(e.AcceptanceCriteria, line)
+		}
+	}
+	return e, nil
+}
+
+// openEditor shows the story form, for story or a new one when it is nil
+func (m *Model) openEditor(story *Story) tea.Cmd {
+	if m.initError != nil {
+		return nil
+	}
+	if story == nil {
+		m.form = newStoryForm("", StoryEdit{ID: nextStoryID(m.stories), Priority: nextPriority(m.stories)})
+	} else {
+		m.form = newStoryForm(story.ID, storyEditOf(*story))
+	}
+	m.form.resize(m.width, m.height)
+	m.overlay = OverlayEditor
+	return m.form.setFocus(fieldTitle)
+}
+
+func (m Model) updateEditor(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	form := m.form
+	switch msg.String() {
+	case "esc":
+		m.overlay = OverlayNone
+		m.form = nil
+		return m, nil
+	case "ctrl+c":
+		return m, m.requestQuit()
+	case "tab":
+		return m, form.setFocus(form.focus + 1)
+	case "shift+tab":
+		return m, form.setFocus(form.focus - 1)
+	case "ctrl+s":
+		e, err := form.edit()
+		if err != nil {
+			form.err = err.Error()
+			return m, nil
+		}
+		if form.originalID != "" && m.storyBusy(form.originalID) {
+			form.err = form.originalID + " is being worked on; save once its iteration ends"
+			return m, nil
+		}
+		form.err = ""
+		action := "Saved " + e.ID
+		if form.originalID == "" {
+			action = "Added " + e.ID
+		}
+		path, originalID := m.prdPath, form.originalID
+		return m, editStoryCmd(path, e.ID, action, func() error { return SaveStory(path, originalID, e) })
+	}
+	return m, form.update(msg)
+}
+
+// storyBusy reports whether an agent is working on the story right now
+func (m Model) storyBusy(id string) bool {
+	return (m.processRunning && m.currentStoryID == id) || m.workerOn(id) != nil
+}
+
+// editSelectedStory handles the Stories panel keys that change prd.json
+func (m *Model) editSelectedStory(key string) tea.Cmd {
+	story := m.selectedStory()
+	if key == "n" {
+		return m.openEditor(nil)
+	}
+	if story == nil || m.initError != nil {
+		return nil
+	}
+	path, id := m.prdPath, story.ID
+	// the agent may rewrite the story as it finishes, so it is left alone
+	if m.storyBusy(id) {
+		m.notify("✗ " + id + " is being worked on")
+		return nil
+	}
+
+	switch key {
+	case "e":
+		return m.openEditor(story)
+	case "D":
+		m.pendingDelete = id
+		m.overlay = OverlayDeleteConfirm
+	case "K":
+		return editStoryCmd(path, id, "Moved "+id+" up", func() error { return MoveStory(path, id, -1) })
+	case "J":
+		return editStoryCmd(path, id, "Moved "+id+" down", func() error { return MoveStory(path, id, 1) })
+	case " ":
+		action := "Marked " + id + " as passing"
+		if story.Passes {
+			action = "Marked " + id + " as not passing"
+		}
+		return editStoryCmd(path, id, action, func() error { return ToggleStoryPasses(path, id) })
+	}
+	return nil
+}
+
+func (m Model) updateDeleteConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	id, path := m.pendingDelete, m.prdPath
+	switch msg.String() {
+	case "y", "Y", "enter":
+		m.overlay = OverlayNone
+		m.pendingDelete = ""
+		return m, editStoryCmd(path, "", "Deleted "+id, func() error { return DeleteStory(path, id) })
+	case "n", "N", "esc":
+		m.overlay = OverlayNone
+		m.pendingDelete = ""
+	case "ctrl+c":
+		return m, m.requestQuit()
+	}
+	return m, nil
+}
+
+// storyEdited applies a finished edit, or reports why it failed: in the
+// form when it is still open, in the status bar otherwise
+func (m *Model) storyEdited(msg StoryEditedMsg) {
+	if msg.Err != nil {
+		if m.overlay == OverlayEditor && m.form != nil {
+			m.form.err = msg.Err.Error()
+		} else {
+			m.notify("✗ " + msg.Err.Error())
+		}
+		return
+	}
+
+	if m.overlay == OverlayEditor {
+		m.overlay = OverlayNone
+		m.form = nil
+	}
+	m.applyPRD(msg.PRD)
+	// an edit can hand work back to a finished run
+	m.processDone = len(m.stories) > 0 && m.completedCount == len(m.stories)
+	m.notify("✓ " + msg.Action)
+
+	for i, s := range m.filterStories() {
+		if s.ID == msg.StoryID {
+			m.storyCursor = i
+		}
+	}
+	m.moveStoryCursor(0)
+}
+
+func (m Model) renderEditor() string {
+	form := m.form
+	title := " Ralph - New Story "
+	if form.originalID != "" {
+		title = " Ralph - Edit " + form.originalID + " "
+	}
+
+	var lines []string
+	for i, field := range form.fields {
+		label := HelpStyle.Render(field.label)
+		if i == form.focus {
+			label = TitleStyle.Render(field.label)
+		}
+		if field.multiline {
+			lines = append(lines, "", label, field.area.View())
+		} else {
+			lines = append(lines, lipgloss.NewStyle().Width(formLabelWidth).Render(label)+field.input.View())
+		}
+	}
+	if form.err != "" {
+		lines = append(lines, "", lipgloss.NewStyle().Foreground(Red).Render("✗ "+form.err))
+	}
+
+	body := PanelActiveStyle.Width(max(30, m.width-4)).Render(strings.Join(lines, "\n"))
+	return lipgloss.JoinVertical(lipgloss.Left,
+		HeaderStyle.Render(title),
+		body,
+		HelpStyle.Render("tab/shift+tab next/prev field │ ctrl+s save │ esc cancel"),
+	)
+}
+
+func (m Model) renderDeleteConfirm() string {
+	question := fmt.Sprintf("Delete %s from prd.json?", TitleStyle.Render(m.pendingDelete))
+	if story := GetStoryByID(m.stories, m.pendingDelete); story != nil {
+		question = fmt.Sprintf("Delete %s: %s from prd.json?", TitleStyle.Render(story.ID), story.Title)
+	}
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(Purple).
+		Padding(1, 3).
+		Render(lipgloss.JoinVertical(lipgloss.Left,
+			HeaderStyle.Render(" Delete Story "),
+			"",
+			question,
+			"",
+			HelpStyle.Render("y: delete │ n/esc: keep it"),
+		))
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
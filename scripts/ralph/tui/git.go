@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// gitOutput runs git in dir and returns its trimmed stdout
+func gitOutput(dir string, args ...string) (string, error) {
+	out, err := gitRawOutput(dir, args...)
+	return strings.TrimSpace(out), err
+}
+
+// gitRawOutput runs git in dir and returns its stdout as is, for output
+// such as patches where whitespace matters
+func gitRawOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func gitTopLevel(dir string) string {
+	top, _ := gitOutput(dir, "rev-parse", "--show-toplevel")
+	return top
+}
+
+// gitHead returns the commit HEAD points at, or "" outside a repository or
+// before the first commit
+func gitHead(dir string) string {
+	head, _ := gitOutput(dir, "rev-parse", "HEAD")
+	return head
+}
+
+// gitCurrentBranch returns the checked-out branch, or "" on a detached HEAD
+func gitCurrentBranch(dir string) string {
+	branch, _ := gitOutput(dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	return branch
+}
+
+func gitBranchExists(dir, branch string) bool {
+	_, err := gitOutput(dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	return err == nil
+}
+
+// gitIgnored reports whether git ignores path; a tracked file never is
+func gitIgnored(dir, path string) bool {
+	_, err := gitOutput(dir, "check-ignore", "--quiet", "--", path)
+	return err == nil
+}
+
+// gitRun runs git in dir, returning its combined output as the error when
+// it fails
+func gitRun(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return nil
+}
+
+// EnsureBranch checks out branch in dir, creating it from main (or the
+// current HEAD when there is no main) if it does not exist yet. It returns a
+// description of what it did, or "" when the branch was already checked out
+// or dir is not a git repository with commits.
+func EnsureBranch(dir, branch string) (string, error) {
+	if branch == "" || gitHead(dir) == "" || gitCurrentBranch(dir) == branch {
+		return "", nil
+	}
+
+	if gitBranchExists(dir, branch) {
+		if err := gitRun(dir, "checkout", branch); err != nil {
+			return "", err
+		}
+		return "checked out " + branch, nil
+	}
+
+	base := "main"
+	if !gitBranchExists(dir, base) {
+		base = "HEAD"
+	}
+	if err := gitRun(dir, "checkout", "-b", branch, base); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("created %s from %s", branch, base), nil
+}
+
+// Commit is a commit made during an iteration
+type Commit struct {
+	SHA     string `json:"sha"`
+	Subject string `json:"subject"`
+	StoryID string `json:"storyId,omitempty"`
+}
+
+// Short is the abbreviated hash
+func (c Commit) Short() string {
+	if len(c.SHA) > 7 {
+		return c.SHA[:7]
+	}
+	return c.SHA
+}
+
+// commitsBetween lists the commits reachable from to but not from, oldest
+// first. Each is linked to the first story ID its subject mentions, or to
+// fallbackStory.
+func commitsBetween(dir, from, to string, stories []Story, fallbackStory string) []Commit {
+	rangeArg := to
+	if from != "" {
+		rangeArg = from + ".." + to
+	}
+	out, err := gitOutput(dir, "log", "--reverse", "--format=%H%x09%s", rangeArg)
+	if err != nil || out == "" {
+		return nil
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(out, "\n") {
+		sha, subject, _ := strings.Cut(line, "\t")
+		storyID := storyInText(subject, stories)
+		if storyID == "" {
+			storyID = fallbackStory
+		}
+		commits = append(commits, Commit{SHA: sha, Subject: subject, StoryID: storyID})
+	}
+	return commits
+}
+
+// storyInText returns the first known story ID that appears in text as a
+// whole word
+func storyInText(text string, stories []Story) string {
+	best, bestIdx := "", -1
+	for _, story := range stories {
+		if story.ID == "" {
+			continue
+		}
+		re := regexp.MustCompile(`(^|[^\w-])` + regexp.QuoteMeta(story.ID) + `($|[^\w-])`)
+		if loc := re.FindStringIndex(text); loc != nil && (bestIdx < 0 || loc[0] < bestIdx) {
+			best, bestIdx = story.ID, loc[0]
+		}
+	}
+	return best
+}
+
+// gitShow renders commits as stat plus patch, newest last
+func gitShow(dir string, shas []string) (string, error) {
+	args := append([]string{"show", "--stat", "--patch", "--format=commit %H%nAuthor: %an%nDate:   %ad%n%n    %s%n"}, shas...)
+	return gitRawOutput(dir, args...)
+}
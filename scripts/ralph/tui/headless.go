@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Exit codes returned by a headless run
+const (
+	ExitComplete      = 0
+	ExitMaxIterations = 1
+	ExitAgentError    = 2
+	ExitInterrupted   = 130
+)
+
+// InterruptMsg is sent when a headless run receives SIGINT or SIGTERM
+type InterruptMsg struct{}
+
+// runHeadless drives the same Update loop as the TUI without rendering and
+// returns the process exit code.
+func runHeadless(m Model) (int, error) {
+	p := tea.NewProgram(
+		m,
+		tea.WithoutRenderer(),
+		tea.WithInput(nil),
+		tea.WithoutSignalHandler(),
+	)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		for range sigs {
+			p.Send(InterruptMsg{})
+		}
+	}()
+
+	final, err := p.Run()
+	if err != nil {
+		return ExitAgentError, err
+	}
+	return final.(Model).exitCode, nil
+}
+
+// finishRun records the outcome of a headless run and stops the program
+func (m *Model) finishRun(code int, reason string) tea.Cmd {
+	m.exitCode = code
+	ev := Event{Type: EventRunEnd, ExitCode: &code, Message: reason}
+	if spent := m.runUsage(); !spent.IsZero() {
+		ev.Usage = &spent
+	}
+	m.report(ev)
+	return tea.Quit
+}
+
+// report emits a progress event when running headless
+func (m *Model) report(ev Event) {
+	if m.reporter == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	ev.Completed = m.completedCount
+	ev.Total = len(m.stories)
+	m.reporter.Report(ev)
+}
+
+// finishHeadless ends a headless run once no iteration is in flight. Only
+// the PRD as last loaded counts as complete, whatever the agent signalled.
+func (m *Model) finishHeadless() tea.Cmd {
+	switch {
+	case m.completedCount == len(m.stories):
+		return m.finishRun(ExitComplete, "all stories complete")
+	case m.agentFailed:
+		return m.finishRun(ExitAgentError, "agent error")
+	case len(m.stuckStories()) > 0 && m.nextStory() == nil:
+		return m.finishRun(ExitMaxIterations, m.stuckSummary())
+	default:
+		return m.finishRun(ExitMaxIterations, "reached max iterations")
+	}
+}
+
+func (m Model) runSummary() string {
+	summary := m.prd.Project
+	if m.prd.BranchName != "" {
+		summary += " (" + m.prd.BranchName + ")"
+	}
+	if m.runner != nil {
+		summary += " · agent: " + m.runner.Name()
+	}
+	if m.parallel() {
+		summary += " · " + strconv.Itoa(len(m.workers)) + " workers"
+	}
+	return summary + " · max " + strconv.Itoa(m.maxIterations) + " iterations"
+}
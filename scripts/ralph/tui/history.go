@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	runsDirName     = ".runs"
+	historyFileName = "history.jsonl"
+)
+
+// IterationRecord is one line of the append-only run history
+type IterationRecord struct {
+	Session   string `json:"session"`
+	Branch    string `json:"branch"`
+	Iteration int    `json:"iteration"`
+	StoryID   string `json:"storyId"`
+	// ClaimedStoryID is set when the agent said it worked on another story
+	ClaimedStoryID string    `json:"claimedStoryId,omitempty"`
+	Agent          string    `json:"agent"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	ExitCode       int       `json:"exitCode"`
+	Passed         bool      `json:"passed"`
+	TimedOut       bool      `json:"timedOut,omitempty"`
+	LogPath        string    `json:"logPath,omitempty"`
+	CommitSHA      string    `json:"commitSha,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Commits        []Commit  `json:"commits,omitempty"`
+	// RolledBack iterations had their changes reset; PatchPath keeps them
+	RolledBack bool   `json:"rolledBack,omitempty"`
+	PatchPath  string `json:"patchPath,omitempty"`
+	// Worker is the parallel worker that ran the iteration, 0 when sequential
+	Worker     int    `json:"worker,omitempty"`
+	MergeError string `json:"mergeError,omitempty"`
+	// Usage is the tokens and cost the agent reported, if it did
+	Usage *TokenUsage `json:"usage,omitempty"`
+
+	Verification *Verification `json:"verification,omitempty"`
+}
+
+// Duration is the wall-clock time the iteration took
+func (r IterationRecord) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+func historyPath(ralphDir string) string {
+	return filepath.Join(ralphDir, runsDirName, historyFileName)
+}
+
+// AppendHistory appends a record to the history file, creating it if needed
+func AppendHistory(path string, rec IterationRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// LoadHistory reads the records for branch, oldest first. Lines that fail to
+// parse (e.g. a write cut short by a crash) are skipped.
+func LoadHistory(path, branch string) ([]IterationRecord, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []IterationRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec IterationRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if branch != "" && rec.Branch != branch {
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	return records, scanner.Err()
+}
+
+// ArchiveHistory moves branch's records out of the history file and into
+// folder, so an archived run starts the next one with a clean slate
+func ArchiveHistory(path, branch, folder string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var keep, archived []byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var rec IterationRecord
+		if json.Unmarshal(line, &rec) == nil && rec.Branch == branch {
+			archived = append(archived, line...)
+		} else {
+			keep = append(keep, line...)
+		}
+	}
+
+	if len(archived) == 0 {
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(folder, historyFileName), archived, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(path, keep, 0o644)
+}
+
+// storyDurationsFromHistory returns, for each story that passed, the
+// duration of the iteration in which it first passed
+func storyDurationsFromHistory(records []IterationRecord) map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	for _, rec := range records {
+		if !rec.Passed || rec.StoryID == "" {
+			continue
+		}
+		if _, seen := durations[rec.StoryID]; !seen {
+			durations[rec.StoryID] = rec.Duration()
+		}
+	}
+	return durations
+}
+
+// attemptsFromHistory counts the iterations per story that did not make it
+// pass
+func attemptsFromHistory(records []IterationRecord) map[string]int {
+	attempts := make(map[string]int)
+	for _, rec := range records {
+		if rec.StoryID != "" && !rec.Passed {
+			attempts[rec.StoryID]++
+		}
+	}
+	return attempts
+}
+
+// storyUsageFromHistory sums the reported usage of every iteration per story
+func storyUsageFromHistory(records []IterationRecord) map[string]TokenUsage {
+	usage := make(map[string]TokenUsage)
+	for _, rec := range records {
+		if rec.Usage == nil {
+			continue
+		}
+		sum := usage[rec.StoryID]
+		sum.Add(*rec.Usage)
+		usage[rec.StoryID] = sum
+	}
+	return usage
+}
+
+// verdictsFromHistory returns the latest verification verdict per story
+func verdictsFromHistory(records []IterationRecord) map[string]Verification {
+	verdicts := make(map[string]Verification)
+	for _, rec := range records {
+		if rec.Verification == nil {
+			continue
+		}
+		for _, id := range rec.Verification.Stories {
+			verdicts[id] = *rec.Verification
+		}
+	}
+	return verdicts
+}
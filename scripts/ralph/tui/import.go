@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var (
+	mdStoryHeading = regexp.MustCompile(`(?i)^#{2,4}\s+Story\s+(\d+)\s*[:.–—-]\s*(.+?)\s*$`)
+	mdHeading      = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+	mdLabel        = regexp.MustCompile(`^\*\*(.+?):\*\*:?\s*(.*)$`)
+	mdBullet       = regexp.MustCompile(`^(\s*)(?:[-*+]|\d+[.)])\s+(?:\[[ xX]\]\s+)?(.*)$`)
+	mdTitleNumber  = regexp.MustCompile(`(?i)^(?:PRD[- ]?)?\d+\s*[-:–]\s*`)
+	mdTitleSuffix  = regexp.MustCompile(`(?i)\s*[-–:]\s*Product Requirements Document$`)
+	mdFileNumber   = regexp.MustCompile(`(?i)^(?:\d+-)?(?:prd-)?(?:\d+-)?`)
+	branchUnsafe   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ParseMarkdownPRD reads a PRD written the way tasks/*.md are: a "# Title",
+// an "## Overview" paragraph and "### Story N: Title" sections whose
+// "**Acceptance Criteria:**" bullet list becomes the story's criteria. The
+// "As a / I want / So that" lines become the description and any other
+// labelled list, such as "**Technical Requirements:**", goes into notes.
+func ParseMarkdownPRD(data []byte) (PRD, error) {
+	var (
+		prd      PRD
+		title    string
+		overview []string
+		section  string
+		inFence  bool
+
+		story   *Story
+		intro   []string
+		label   string
+		notes   []string
+		bullets []string
+	)
+
+	flushList := func() {
+		if story == nil || len(bullets) == 0 {
+			bullets = nil
+			return
+		}
+		if strings.EqualFold(label, "acceptance criteria") {
+			story.AcceptanceCriteria = append(story.AcceptanceCriteria, bullets...)
+		} else {
+			notes = append(notes, label+": "+strings.Join(bullets, "; "))
+		}
+		bullets = nil
+	}
+	flushStory := func() {
+		flushList()
+		if story == nil {
+			return
+		}
+		story.Description = joinUserStory(intro)
+		story.Notes = strings.Join(notes, "\n")
+		if story.AcceptanceCriteria == nil {
+			story.AcceptanceCriteria = []string{}
+		}
+		prd.UserStories = append(prd.UserStories, *story)
+		story, intro, label, notes = nil, nil, "", nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		if m := mdStoryHeading.FindStringSubmatch(line); m != nil {
+			flushStory()
+			n, _ := strconv.Atoi(m[1])
+			story = &Story{
+				ID:       fmt.Sprintf("US-%03d", n),
+				Title:    plainMarkdown(m[2]),
+				Priority: len(prd.UserStories) + 1,
+			}
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			flushStory()
+			if len(m[1]) == 1 && title == "" {
+				title = plainMarkdown(m[2])
+			} else if len(m[1]) == 2 {
+				section = strings.ToLower(plainMarkdown(m[2]))
+			}
+			continue
+		}
+
+		if story == nil {
+			if section == "overview" || section == "introduction" || section == "summary" {
+				switch {
+				case line == "" && len(overview) > 0:
+					section = ""
+				case line != "":
+					overview = append(overview, plainMarkdown(line))
+				}
+			}
+			continue
+		}
+
+		if m := mdLabel.FindStringSubmatch(line); m != nil {
+			flushList()
+			label = plainMarkdown(m[1])
+			if m[2] != "" {
+				notes = append(notes, label+": "+plainMarkdown(m[2]))
+			}
+			continue
+		}
+		if label == "" {
+			if line != "" {
+				intro = append(intro, plainMarkdown(line))
+			}
+			continue
+		}
+		if m := mdBullet.FindStringSubmatch(line); m != nil {
+			text := plainMarkdown(m[2])
+			if m[1] != "" && len(bullets) > 0 {
+				// nested bullets detail their parent
+				last := &bullets[len(bullets)-1]
+				sep := "; "
+				if strings.HasSuffix(*last, ":") {
+					sep = " "
+				} else if !strings.Contains(*last, ": ") {
+					sep = ": "
+				}
+				*last += sep + text
+			} else if text != "" {
+				bullets = append(bullets, text)
+			}
+		}
+	}
+	flushStory()
+	if err := scanner.Err(); err != nil {
+		return PRD{}, err
+	}
+
+	if len(prd.UserStories) == 0 {
+		return PRD{}, errors.New(`no "### Story N: Title" sections found`)
+	}
+
+	title = mdTitleSuffix.ReplaceAllString(mdTitleNumber.ReplaceAllString(title, ""), "")
+	prd.Project = title
+	prd.Description = strings.Join(overview, " ")
+	if title != "" && prd.Description != "" {
+		prd.Description = title + " - " + prd.Description
+	} else if prd.Description == "" {
+		prd.Description = title
+	}
+	return prd, nil
+}
+
+// joinUserStory turns the "As a / I want / So that" lines into a sentence
+func joinUserStory(lines []string) string {
+	for i := 1; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "So ") {
+			lines[i] = "so " + lines[i][3:]
+		}
+	}
+	return strings.Join(lines, ", ")
+}
+
+// plainMarkdown drops emphasis markers and surrounding space
+func plainMarkdown(s string) string {
+	s = strings.ReplaceAll(s, "**", "")
+	s = strings.ReplaceAll(s, "__", "")
+	return strings.TrimSpace(s)
+}
+
+// branchForTasksFile derives a branch from a tasks file name, e.g.
+// ralph/product-variants from 16-prd-product-variants.md
+func branchForTasksFile(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name = mdFileNumber.ReplaceAllString(strings.ToLower(name), "")
+	name = strings.Trim(branchUnsafe.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		name = "import"
+	}
+	return "ralph/" + name
+}
+
+// importedDocument lays prd out with the key order prd.json files use
+func importedDocument(prd PRD) (*PRDDocument, error) {
+	doc := &PRDDocument{
+		root:         jsonObject{values: map[string]json.RawMessage{}},
+		indent:       "\t",
+		finalNewline: true,
+	}
+	for _, f := range []struct {
+		key   string
+		value any
+	}{
+		{"project", prd.Project},
+		{"branchName", prd.BranchName},
+		{"description", prd.Description},
+	} {
+		if err := doc.root.Set(f.key, f.value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range prd.UserStories {
+		story := &jsonObject{values: map[string]json.RawMessage{}}
+		for _, f := range []struct {
+			key   string
+			value any
+		}{
+			{"id", s.ID},
+			{"title", s.Title},
+			{"description", s.Description},
+			{"acceptanceCriteria", s.AcceptanceCriteria},
+			{"priority", s.Priority},
+			{"passes", s.Passes},
+			{"notes", s.Notes},
+		} {
+			if err := story.Set(f.key, f.value); err != nil {
+				return nil, err
+			}
+		}
+		doc.Stories = append(doc.Stories, story)
+	}
+	return doc, nil
+}
+
+// runImport implements `ralph-tui import [flags] tasks/<file>.md`: it turns a
+// markdown PRD into prd.json after the user confirms a preview, and returns
+// the exit code
+func runImport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configFlag := fs.String("config", "", "config file naming the PRD to write when --out is not given")
+	outFlag := fs.String("out", "", "prd.json to write (default: the configured PRD)")
+	branchFlag := fs.String("branch", "", "branchName for the PRD (default: ralph/<file name>)")
+	projectFlag := fs.String("project", "", "project name (default: the PRD being replaced, else the markdown title)")
+	yesFlag := fs.Bool("yes", false, "write without showing the preview")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ralph-tui import [flags] tasks/<file>.md")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	source := fs.Arg(0)
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	prd, err := ParseMarkdownPRD(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s: %v\n", source, err)
+		return 1
+	}
+
+	target := *outFlag
+	if target == "" {
+		if target, err = defaultPRDPath(*configFlag); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 2
+		}
+	}
+
+	var replaces *PRD
+	if _, err := os.Stat(target); err == nil {
+		current, err := LoadPRD(target)
+		if err == nil {
+			replaces = &current
+		} else {
+			replaces = &PRD{}
+		}
+	}
+
+	prd.BranchName = branchForTasksFile(source)
+	if *branchFlag != "" {
+		prd.BranchName = *branchFlag
+	}
+	switch {
+	case *projectFlag != "":
+		prd.Project = *projectFlag
+	case replaces != nil && replaces.Project != "":
+		prd.Project = replaces.Project
+	}
+
+	doc, err := importedDocument(prd)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	out, err := doc.Bytes()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if problems := ValidatePRD(out); len(problems) > 0 {
+		fmt.Fprintf(stderr, "Error: %s does not produce a valid PRD:\n", source)
+		for _, p := range problems {
+			fmt.Fprintf(stderr, "  %s\n", p)
+		}
+		return 1
+	}
+
+	if !*yesFlag {
+		preview := newImportPreview(prd, source, target, replaces)
+		final, err := tea.NewProgram(preview, tea.WithAltScreen()).Run()
+		if err != nil {
+			fmt.Fprintf(stderr, "Error running preview: %v\n", err)
+			return 1
+		}
+		if !final.(importPreview).confirmed {
+			fmt.Fprintln(stdout, "Import cancelled; nothing written")
+			return 1
+		}
+	}
+
+	// the PRD being replaced would otherwise be lost, or archived later
+	// under the new PRD's content
+	if replaces != nil {
+		progressPath := filepath.Join(filepath.Dir(target), progressFileName)
+		folder, err := ArchivePreviousRun(target, progressPath, replaces.BranchName, prd.BranchName, time.Now())
+		if err != nil {
+			fmt.Fprintf(stderr, "Error archiving the current PRD: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Archived the previous run to %s\n", folder)
+	}
+
+	if err := writeFileAtomic(target, out); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Imported %d stories from %s into %s (branch %s)\n", len(prd.UserStories), source, target, prd.BranchName)
+	return 0
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportArchivesReplacedPRD(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1))
+	ralphDir := filepath.Dir(prdPath)
+	old, err := os.ReadFile(prdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := filepath.Join(t.TempDir(), "prd-export.md")
+	markdown := "# PRD: Export\n\n## User Stories\n\n### Story 1: Export orders\n**Description:** As an owner I want a CSV.\n\n**Acceptance Criteria:**\n- [ ] CSV has a header\n"
+	if err := os.WriteFile(tasks, []byte(markdown), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := runImport([]string{"--yes", "--out", prdPath, tasks}, io.Discard, io.Discard); code != 0 {
+		t.Fatalf("import exited %d", code)
+	}
+
+	runs, err := ListArchives(prdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("archived %d runs, want the replaced one", len(runs))
+	}
+	archived, err := os.ReadFile(filepath.Join(runs[0].Path, "prd.json"))
+	if err != nil || string(archived) != string(old) {
+		t.Errorf("archived PRD = %q (%v), want the replaced one", archived, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ralphDir, lastBranchFileName)); string(data) != "ralph/export\n" {
+		t.Errorf(".last-branch = %q, want the imported PRD's branch", data)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// importPreview shows what `ralph-tui import` is about to write and waits
+// for the user to confirm
+type importPreview struct {
+	prd      PRD
+	source   string
+	target   string
+	replaces *PRD
+	viewport viewport.Model
+	width    int
+
+	confirmed bool
+}
+
+func newImportPreview(prd PRD, source, target string, replaces *PRD) importPreview {
+	return importPreview{
+		prd:      prd,
+		source:   source,
+		target:   target,
+		replaces: replaces,
+		viewport: viewport.New(80, 20),
+	}
+}
+
+func (p importPreview) Init() tea.Cmd {
+	return nil
+}
+
+func (p importPreview) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.width = msg.Width
+		p.viewport.Width = max(20, msg.Width-4)
+		p.viewport.Height = max(5, msg.Height-6)
+		p.viewport.SetContent(p.renderContent())
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "y", "Y", "enter":
+			p.confirmed = true
+			return p, tea.Quit
+		case "n", "N", "esc", "q", "ctrl+c":
+			return p, tea.Quit
+		case "up", "k":
+			p.viewport.LineUp(1)
+		case "down", "j":
+			p.viewport.LineDown(1)
+		case "pgup", "ctrl+u":
+			p.viewport.HalfViewUp()
+		case "pgdown", "ctrl+d", " ":
+			p.viewport.HalfViewDown()
+		case "g":
+			p.viewport.GotoTop()
+		case "G":
+			p.viewport.GotoBottom()
+		}
+	}
+	return p, nil
+}
+
+func (p importPreview) renderContent() string {
+	wrap := lipgloss.NewStyle().Width(p.viewport.Width)
+	indent := func(n int) lipgloss.Style { return wrap.PaddingLeft(n) }
+	warn := lipgloss.NewStyle().Foreground(Yellow)
+
+	lines := []string{
+		TitleStyle.Render(p.prd.Project) + HelpStyle.Render(" ("+p.prd.BranchName+")"),
+		wrap.Inherit(HelpStyle).Render(p.prd.Description),
+		"",
+	}
+	if r := p.replaces; r != nil {
+		lines = append(lines, warn.Render(fmt.Sprintf("⚠ Replaces %s: %s (%s), %d/%d stories passing; it is archived first",
+			p.target, r.Project, r.BranchName, CountCompleted(r.UserStories), len(r.UserStories))), "")
+	}
+
+	for _, story := range p.prd.UserStories {
+		lines = append(lines, fmt.Sprintf("%s %s %s", PendingIcon, StoryCurrentStyle.Render(story.ID), story.Title))
+		if story.Description != "" {
+			lines = append(lines, indent(2).Inherit(HelpStyle).Render(story.Description))
+		}
+		for _, criterion := range story.AcceptanceCriteria {
+			lines = append(lines, indent(4).Render("• "+criterion))
+		}
+		if len(story.AcceptanceCriteria) == 0 {
+			lines = append(lines, warn.Render("    no acceptance criteria"))
+		}
+		if story.Notes != "" {
+			lines = append(lines, indent(2).Inherit(HelpStyle).Render("Notes: "+strings.ReplaceAll(story.Notes, "\n", " │ ")))
+		}
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (p importPreview) View() string {
+	if p.width == 0 {
+		return "Loading..."
+	}
+
+	title := fmt.Sprintf(" Ralph - Import %s ", p.source)
+	footer := fmt.Sprintf("%d stories │ ↑/↓ scroll │ y/enter write %s │ n/esc cancel", len(p.prd.UserStories), p.target)
+	body := PanelActiveStyle.Width(p.viewport.Width + 2).Height(p.viewport.Height).Render(p.viewport.View())
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		HeaderStyle.Render(title),
+		body,
+		HelpStyle.Render(footer),
+	)
+}
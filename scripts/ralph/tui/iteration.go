@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// iterationSpec describes a single agent run
+type iterationSpec struct {
+	Worker        int
+	Iteration     int
+	MaxIterations int
+	StoryID       string
+	Pinned        bool // StoryID was picked by hand rather than by priority
+	Runner        AgentRunner
+	PRDPath       string
+	PromptPath    string
+	ProgressPath  string
+	ProjectRoot   string
+	RalphDir      string
+	// PreviousLogPath is the log of the story's last, unsuccessful attempt
+	PreviousLogPath string
+	// Verifier checks stories the agent marks as passing; nil trusts the agent
+	Verifier *Verifier
+	// Branch is checked out, and created if needed, before the agent starts
+	Branch string
+	// Rollback resets the working tree when the iteration fails
+	Rollback bool
+}
+
+// output shows a status line in the iteration's output pane
+func (s iterationSpec) output(msgChan chan<- interface{}, line string) {
+	msgChan <- OutputLineMsg{Worker: s.Worker, Line: line, Timestamp: time.Now()}
+}
+
+// runIterationCmd runs one agent iteration. Everything it produces —
+// start, output lines and exit — is delivered in order through msgChan.
+func runIterationCmd(spec iterationSpec, msgChan chan<- interface{}) tea.Cmd {
+	return func() tea.Msg {
+		msgChan <- runIteration(spec, msgChan)
+		return nil
+	}
+}
+
+func runIteration(spec iterationSpec, msgChan chan<- interface{}) ProcessExitedMsg {
+	exited := ProcessExitedMsg{
+		Worker:    spec.Worker,
+		Iteration: spec.Iteration,
+		StoryID:   spec.StoryID,
+		ExitCode:  1,
+		Start:     time.Now(),
+	}
+
+	prompt, err := iterationPrompt(spec)
+	if err != nil {
+		exited.Err = err
+		exited.End = time.Now()
+		return exited
+	}
+
+	did, err := EnsureBranch(spec.ProjectRoot, spec.Branch)
+	if err != nil {
+		exited.Err = err
+		exited.End = time.Now()
+		return exited
+	}
+	if did != "" {
+		spec.output(msgChan, "[git] "+did)
+	}
+
+	headBefore := gitHead(spec.ProjectRoot)
+	prdBefore, _ := LoadPRD(spec.PRDPath)
+
+	var snapshot *rollbackSnapshot
+	if spec.Rollback {
+		var reason string
+		snapshot, reason = takeRollbackSnapshot(spec.ProjectRoot, filepath.Join(spec.RalphDir, runsDirName),
+			spec.PRDPath, spec.ProgressPath, filepath.Join(spec.RalphDir, lastBranchFileName))
+		if snapshot == nil {
+			spec.output(msgChan, "[rollback] disabled for this iteration: "+reason)
+		}
+	}
+
+	// A missing log only costs the audit trail, so the iteration still runs
+	log, err := createIterationLog(spec.RalphDir, spec.Iteration, spec.StoryID, exited.Start)
+	if err == nil {
+		defer log.Close()
+		exited.LogPath = log.Path
+		log.WriteLine(exited.Start, "", fmt.Sprintf("iteration %d · story %s · agent %s", spec.Iteration, spec.StoryID, spec.Runner.Name()))
+	}
+
+	proc, err := spec.Runner.Start(prompt, spec.ProjectRoot)
+	if err != nil {
+		exited.Err = err
+		exited.End = time.Now()
+		return exited
+	}
+
+	msgChan <- ProcessStartedMsg{
+		Worker:    spec.Worker,
+		Iteration: spec.Iteration,
+		StoryID:   spec.StoryID,
+		Proc:      proc,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		streamOutput(proc.Stdout(), spec.Worker, "", log, msgChan)
+	}()
+	go func() {
+		defer wg.Done()
+		streamOutput(proc.Stderr(), spec.Worker, "stderr", log, msgChan)
+	}()
+	wg.Wait()
+
+	exited.ExitCode, exited.Err = proc.Wait()
+	exited.End = time.Now()
+	log.WriteLine(exited.End, "", fmt.Sprintf("exit code %d", exited.ExitCode))
+
+	if headAfter := gitHead(spec.ProjectRoot); headAfter != headBefore {
+		exited.CommitSHA = headAfter
+		exited.Commits = commitsBetween(spec.ProjectRoot, headBefore, headAfter, prdBefore.UserStories, spec.StoryID)
+	}
+	if spec.Verifier != nil {
+		exited.Verification, err = verifyIteration(spec, prdBefore, log, msgChan)
+		if err != nil && exited.Err == nil {
+			exited.Err = err
+		}
+	}
+	if prd, loadErr := LoadPRD(spec.PRDPath); loadErr == nil {
+		exited.PRD = &prd
+	}
+
+	if snapshot != nil && iterationFailed(exited) {
+		if err := rollbackIteration(spec, snapshot, &exited, log, msgChan); err != nil && exited.Err == nil {
+			exited.Err = err
+		}
+	}
+	return exited
+}
+
+// iterationFailed reports whether the agent failed or left its story failing
+func iterationFailed(exited ProcessExitedMsg) bool {
+	if exited.ExitCode != 0 || exited.Err != nil || exited.PRD == nil {
+		return true
+	}
+	story := GetStoryByID(exited.PRD.UserStories, exited.StoryID)
+	return story != nil && !story.Passes
+}
+
+// rollbackIteration restores the snapshot, keeping the discarded work as a
+// patch next to the iteration logs
+func rollbackIteration(spec iterationSpec, snapshot *rollbackSnapshot, exited *ProcessExitedMsg, log *iterationLog, msgChan chan<- interface{}) error {
+	out := func(line string) {
+		now := time.Now()
+		log.WriteLine(now, "rollback", line)
+		msgChan <- OutputLineMsg{Worker: spec.Worker, Line: "[rollback] " + line, Timestamp: now}
+	}
+
+	path := patchPath(spec.RalphDir, spec.Iteration, spec.StoryID, exited.Start)
+	if err := snapshot.Restore(path); err != nil {
+		out("failed: " + err.Error())
+		return fmt.Errorf("rollback: %w", err)
+	}
+	exited.RolledBack = true
+
+	if _, err := os.Stat(path); err == nil {
+		exited.PatchPath = path
+		out("reset to " + snapshot.head[:7] + "; discarded changes saved to " + path)
+	} else {
+		out("reset to " + snapshot.head[:7] + "; nothing to discard")
+	}
+	return nil
+}
+
+// verifyIteration runs the checks when the agent marked stories as passing,
+// reverting them if a check fails. Check output is shown and logged like the
+// agent's own.
+func verifyIteration(spec iterationSpec, before PRD, log *iterationLog, msgChan chan<- interface{}) (*Verification, error) {
+	after, err := LoadPRD(spec.PRDPath)
+	if err != nil {
+		return nil, nil
+	}
+	flipped := newlyPassing(before.UserStories, after.UserStories)
+	if len(flipped) == 0 {
+		return nil, nil
+	}
+
+	out := func(stream, line string) {
+		now := time.Now()
+		log.WriteLine(now, "check", line)
+		if stream != "" {
+			line = stream + ": " + line
+		}
+		msgChan <- OutputLineMsg{Worker: spec.Worker, Line: "[verify] " + line, Timestamp: now}
+	}
+
+	out("", "verifying "+strings.Join(flipped, ", "))
+	verifier := *spec.Verifier
+	verifier.Dir = spec.ProjectRoot
+	v := verifier.Run(flipped, out)
+	out("", v.Summary())
+
+	if !v.Passed {
+		if err := RejectStories(spec.PRDPath, v, time.Now()); err != nil {
+			return &v, fmt.Errorf("reverting unverified stories: %w", err)
+		}
+	}
+	return &v, nil
+}
+
+// iterationPrompt renders prompt.md for the iteration
+func iterationPrompt(spec iterationSpec) (string, error) {
+	text, err := os.ReadFile(spec.PromptPath)
+	if err != nil {
+		return "", err
+	}
+	prompt, err := RenderPrompt(string(text), buildPromptData(spec))
+	if err != nil {
+		return "", err
+	}
+	// the loop chooses the story, skipping stuck ones and waiting on
+	// dependencies, and workers must not all take the same one
+	if spec.StoryID != "" && !namesStory(string(text)) {
+		prompt = pinStoryPrompt(prompt, spec.StoryID, spec.Pinned)
+	}
+	return prompt, nil
+}
+
+// pinStoryPrompt tells the agent to work on storyID instead of choosing the
+// highest priority story itself. A story picked by hand is worked on even
+// when it already passes.
+func pinStoryPrompt(prompt, storyID string, picked bool) string {
+	reason := "as the loop scheduled it; other stories may be stuck, blocked or in progress elsewhere"
+	if picked {
+		reason = "even if other stories have a higher priority or it already passes"
+	}
+	return prompt + fmt.Sprintf(`
+
+## Story Override
+
+For this iteration, work on user story **%s** only, %s. Every other instruction above still applies.
+`, storyID, reason)
+}
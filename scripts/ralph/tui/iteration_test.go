@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIterationPromptNamesScheduledStory(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1), testStory("US-002", 2))
+	ralphDir := filepath.Dir(prdPath)
+	plain := filepath.Join(ralphDir, "prompt.md")
+	templated := filepath.Join(ralphDir, "templated.md")
+	if err := os.WriteFile(templated, []byte("{{with .Story}}Your story is {{.ID}}.{{end}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		spec   iterationSpec
+		want   string
+		absent string
+	}{
+		{"sequential", iterationSpec{StoryID: "US-002", PromptPath: plain}, "user story **US-002** only", ""},
+		{"worker", iterationSpec{Worker: 2, StoryID: "US-002", PromptPath: plain}, "user story **US-002** only", ""},
+		{"picked", iterationSpec{StoryID: "US-002", Pinned: true, PromptPath: plain}, "it already passes", ""},
+		{"templated worker", iterationSpec{Worker: 2, StoryID: "US-002", PromptPath: templated}, "Your story is US-002.", "Story Override"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.spec.PRDPath = prdPath
+			prompt, err := iterationPrompt(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(prompt, tc.want) {
+				t.Errorf("prompt misses %q:\n%s", tc.want, prompt)
+			}
+			if tc.absent != "" && strings.Contains(prompt, tc.absent) {
+				t.Errorf("prompt has %q:\n%s", tc.absent, prompt)
+			}
+		})
+	}
+}
+
+func TestIterationPromptWithoutStoryLetsAgentPick(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1))
+	prompt, err := iterationPrompt(iterationSpec{PRDPath: prdPath, PromptPath: filepath.Join(filepath.Dir(prdPath), "prompt.md")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(prompt, "Story Override") {
+		t.Errorf("prompt names a story though none was scheduled:\n%s", prompt)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ProgressUpdatedMsg carries progress.txt after it changed on disk
+type ProgressUpdatedMsg struct {
+	Progress ProgressLog
+	Err      error
+}
+
+// toggleLearnings swaps the Output panel for the Learnings panel and back,
+// keeping focus on the right-hand side if it was there
+func (m *Model) toggleLearnings() {
+	m.showLearnings = !m.showLearnings
+	switch {
+	case m.showLearnings && m.focusedPanel == PanelOutput:
+		m.focusedPanel = PanelLearnings
+		m.focusedWorker = 0
+	case !m.showLearnings && m.focusedPanel == PanelLearnings:
+		m.focusedPanel = PanelOutput
+	}
+	if m.showLearnings {
+		m.followSelectedStory()
+	}
+}
+
+// updateLearnings handles the keys specific to the focused Learnings panel
+// and reports whether msg was one of them
+func (m *Model) updateLearnings(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "n":
+		m.selectLearning(m.learningsEntry+1, true)
+	case "N":
+		if m.learningsEntry < 0 {
+			m.selectLearning(len(m.progress.Entries)-1, true)
+		} else {
+			m.selectLearning(m.learningsEntry-1, true)
+		}
+	case "enter":
+		// jump to the entry's story
+		m.focusedPanel = PanelStories
+	default:
+		return false
+	}
+	return true
+}
+
+// selectLearning highlights entry idx and scrolls to it; with linkStory the
+// Stories panel selects the entry's story too
+func (m *Model) selectLearning(idx int, linkStory bool) {
+	if idx < 0 || idx >= len(m.progress.Entries) {
+		return
+	}
+	m.learningsEntry = idx
+
+	if storyID := m.progress.Entries[idx].StoryID; linkStory && storyID != "" {
+		for i, s := range m.filterStories() {
+			if s.ID == storyID {
+				m.storyCursor = i
+			}
+		}
+		m.moveStoryCursor(0)
+	}
+
+	m.refreshLearnings()
+	m.learningsViewport.SetYOffset(m.learningsLines[idx])
+}
+
+// followSelectedStory shows the latest entry for the story selected in the
+// Stories panel
+func (m *Model) followSelectedStory() {
+	m.learningsEntry = -1
+	if story := m.selectedStory(); story != nil {
+		for i, e := range m.progress.Entries {
+			if e.StoryID == story.ID {
+				m.learningsEntry = i
+			}
+		}
+	}
+	m.refreshLearnings()
+	if m.learningsEntry >= 0 {
+		m.learningsViewport.SetYOffset(m.learningsLines[m.learningsEntry])
+	}
+}
+
+// resizeLearningsViewport fits the Learnings panel where the Output panel
+// would be
+func (m *Model) resizeLearningsViewport() {
+	leftWidth := m.width/2 - panelHorizontalPad
+	panelHeight := max(minPanelHeight, m.height-totalUIOverhead)
+	m.learningsViewport.Width = max(20, m.width-leftWidth-panelGap-4)
+	m.learningsViewport.Height = max(1, panelHeight-3)
+	m.refreshLearnings()
+}
+
+// refreshLearnings renders progress.txt into the Learnings viewport,
+// remembering the line each entry starts on
+func (m *Model) refreshLearnings() {
+	width := m.learningsViewport.Width
+	wrap := lipgloss.NewStyle().Width(width)
+
+	var selectedID string
+	if story := m.selectedStory(); story != nil {
+		selectedID = story.ID
+	}
+
+	var blocks []string
+	lineCount := 0
+	add := func(block string) {
+		blocks = append(blocks, block)
+		lineCount += lipgloss.Height(block)
+	}
+
+	if m.progress.Patterns != "" {
+		add(TitleStyle.Render(patternsHeading))
+		add(wrap.Render(m.progress.Patterns))
+		add("")
+	}
+
+	m.learningsLines = make([]int, len(m.progress.Entries))
+	for i, e := range m.progress.Entries {
+		m.learningsLines[i] = lineCount
+		switch {
+		case i == m.learningsEntry:
+			add(StoryCurrentStyle.Render(CurrentIcon + " " + e.Heading))
+		case e.StoryID != "" && e.StoryID == selectedID:
+			add(TitleStyle.Render("  " + e.Heading))
+		default:
+			add(StoryPendingStyle.Render("  " + e.Heading))
+		}
+		if e.Body != "" {
+			add(wrap.Inherit(HelpStyle).Render(e.Body))
+		}
+		add("")
+	}
+
+	if len(blocks) == 0 {
+		add(HelpStyle.Render("Nothing in " + m.progressPath + " yet"))
+	}
+	m.learningsViewport.SetContent(strings.Join(blocks, "\n"))
+}
+
+// storyLearnings counts the progress entries written for a story
+func (m Model) storyLearnings(storyID string) int {
+	return len(m.progress.StoryEntries(storyID))
+}
+
+func (m Model) renderLearningsPanel(width, height int) string {
+	style := PanelStyle.Width(width).Height(height)
+	if m.focusedPanel == PanelLearnings {
+		style = PanelActiveStyle.Width(width).Height(height)
+	}
+
+	info := fmt.Sprintf(" · %d entries", len(m.progress.Entries))
+	if story := m.selectedStory(); story != nil {
+		if n := m.storyLearnings(story.ID); n > 0 {
+			info += fmt.Sprintf(" · %d for %s", n, story.ID)
+		}
+	}
+	hint := "n/N entry · enter story · l output"
+	if lipgloss.Width("Learnings"+info+" │ "+hint) > width-4 {
+		hint = ""
+	} else {
+		hint = " │ " + hint
+	}
+	title := PanelTitleStyle.Render("Learnings" + info + hint)
+
+	content := lipgloss.JoinVertical(lipgloss.Left, title, m.learningsViewport.View())
+	return style.Render(content)
+}
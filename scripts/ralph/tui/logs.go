@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const logsDirName = "logs"
+
+// iterationLog captures one iteration's stdout and stderr in its own file
+type iterationLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	Path string
+}
+
+// createIterationLog opens a timestamped log file under .runs/logs
+func createIterationLog(ralphDir string, iteration int, storyID string, start time.Time) (*iterationLog, error) {
+	dir := filepath.Join(ralphDir, runsDirName, logsDirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%s-iter%d", start.Format("20060102-150405"), iteration)
+	if storyID != "" {
+		name += "-" + sanitizeFileName(storyID)
+	}
+	path := filepath.Join(dir, name+".log")
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &iterationLog{f: f, Path: path}, nil
+}
+
+// WriteLine appends a timestamped line; safe for concurrent streams
+func (l *iterationLog) WriteLine(t time.Time, stream, line string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prefix := formatTimestamp(t)
+	if stream != "" {
+		prefix += " " + stream
+	}
+	fmt.Fprintln(l.f, prefix+" "+line)
+}
+
+func (l *iterationLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
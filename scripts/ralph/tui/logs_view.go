@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const logListWidth = 50
+
+// logRecord returns the i-th history record, newest first
+func (m Model) logRecord(i int) *IterationRecord {
+	if i < 0 || i >= len(m.history) {
+		return nil
+	}
+	return &m.history[len(m.history)-1-i]
+}
+
+func (m *Model) openLogBrowser() tea.Cmd {
+	m.overlay = OverlayLogs
+	m.logSearching = false
+	m.resizeLogViewport()
+	return m.selectLog(0)
+}
+
+// selectLog moves the cursor and loads the selected iteration's log
+func (m *Model) selectLog(idx int) tea.Cmd {
+	if len(m.history) == 0 {
+		m.logLines = nil
+		m.logViewport.SetContent(HelpStyle.Render("No iterations recorded yet"))
+		return nil
+	}
+	if idx < 0 || idx >= len(m.history) {
+		return nil
+	}
+
+	m.logCursor = idx
+	m.logMatches = nil
+	rec := m.logRecord(idx)
+	path := m.logPath(rec)
+	if path == "" {
+		m.logLines = nil
+		if m.logShowPatch {
+			m.logViewport.SetContent(HelpStyle.Render("No discarded changes for this iteration"))
+		} else {
+			m.logViewport.SetContent(HelpStyle.Render("No log file for this iteration"))
+		}
+		return nil
+	}
+	return loadLogCmd(path)
+}
+
+// logPath is the file shown for rec: its log, or the patch of a rolled
+// back iteration when toggled with 'p'
+func (m Model) logPath(rec *IterationRecord) string {
+	if rec == nil {
+		return ""
+	}
+	if m.logShowPatch {
+		return rec.PatchPath
+	}
+	return rec.LogPath
+}
+
+func (m Model) updateLogBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.logSearching {
+		switch msg.String() {
+		case "esc":
+			m.logSearching = false
+			m.logQuery = ""
+			m.logMatches = nil
+			m.refreshLogView()
+		case "enter":
+			m.logSearching = false
+			m.findLogMatches()
+		case "backspace":
+			if len(m.logQuery) > 0 {
+				m.logQuery = m.logQuery[:len(m.logQuery)-1]
+			}
+		default:
+			if len(msg.String()) == 1 {
+				m.logQuery += msg.String()
+			}
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc", "q", "L":
+		m.overlay = OverlayNone
+	case "ctrl+c":
+		return m, m.requestQuit()
+	case "up", "k":
+		return m, m.selectLog(m.logCursor - 1)
+	case "down", "j":
+		return m, m.selectLog(m.logCursor + 1)
+	case "K":
+		m.logViewport.LineUp(1)
+	case "J":
+		m.logViewport.LineDown(1)
+	case "pgup", "ctrl+u":
+		m.logViewport.HalfViewUp()
+	case "pgdown", "ctrl+d":
+		m.logViewport.HalfViewDown()
+	case "g":
+		m.logViewport.GotoTop()
+	case "G":
+		m.logViewport.GotoBottom()
+	case "/":
+		m.logSearching = true
+		m.logQuery = ""
+	case "n":
+		m.jumpLogMatch(1)
+	case "N":
+		m.jumpLogMatch(-1)
+	case "p":
+		m.logShowPatch = !m.logShowPatch
+		return m, m.selectLog(m.logCursor)
+	}
+	return m, nil
+}
+
+// findLogMatches records the lines containing the query and jumps to the first
+func (m *Model) findLogMatches() {
+	m.logMatches = nil
+	m.logMatchIdx = 0
+	if m.logQuery != "" {
+		query := strings.ToLower(m.logQuery)
+		for i, line := range m.logLines {
+			if strings.Contains(strings.ToLower(line), query) {
+				m.logMatches = append(m.logMatches, i)
+			}
+		}
+	}
+	m.refreshLogView()
+	if len(m.logMatches) > 0 {
+		m.logViewport.SetYOffset(m.logMatches[0])
+	}
+}
+
+func (m *Model) jumpLogMatch(delta int) {
+	if len(m.logMatches) == 0 {
+		return
+	}
+	m.logMatchIdx = (m.logMatchIdx + delta + len(m.logMatches)) % len(m.logMatches)
+	m.logViewport.SetYOffset(m.logMatches[m.logMatchIdx])
+}
+
+// refreshLogView renders the loaded log, highlighting search matches
+func (m *Model) refreshLogView() {
+	if m.logQuery == "" || len(m.logMatches) == 0 {
+		if m.logShowPatch {
+			m.logViewport.SetContent(colorizeDiff(strings.Join(m.logLines, "\n")))
+		} else {
+			m.logViewport.SetContent(strings.Join(m.logLines, "\n"))
+		}
+		return
+	}
+
+	lines := make([]string, len(m.logLines))
+	copy(lines, m.logLines)
+	for _, i := range m.logMatches {
+		lines[i] = highlightMatches(lines[i], m.logQuery)
+	}
+	m.logViewport.SetContent(strings.Join(lines, "\n"))
+}
+
+func (m *Model) resizeLogViewport() {
+	m.logViewport.Width = max(20, m.width-logListWidth-12)
+	m.logViewport.Height = max(5, m.height-8)
+}
+
+// highlightMatches marks every case-insensitive occurrence of query in line
+func highlightMatches(line, query string) string {
+	lower := strings.ToLower(line)
+	query = strings.ToLower(query)
+
+	var b strings.Builder
+	for {
+		idx := strings.Index(lower, query)
+		if idx < 0 {
+			b.WriteString(line)
+			return b.String()
+		}
+		b.WriteString(line[:idx])
+		b.WriteString(SearchMatchStyle.Render(line[idx : idx+len(query)]))
+		line = line[idx+len(query):]
+		lower = lower[idx+len(query):]
+	}
+}
+
+func (m Model) renderLogBrowser() string {
+	panelHeight := max(minPanelHeight, m.height-6)
+
+	var lines []string
+	for i := range m.history {
+		rec := m.logRecord(i)
+		icon := ErrorIcon
+		if rec.Passed {
+			icon = SuccessIcon
+		} else if rec.ExitCode == 0 && rec.Error == "" {
+			icon = PendingIcon
+		}
+
+		rolledBack := " "
+		if rec.RolledBack {
+			rolledBack = "↺"
+		}
+
+		line := fmt.Sprintf("%s #%-3d %-9s %7s exit %-3d %s%s",
+			rec.Start.Local().Format("01-02 15:04"), rec.Iteration, rec.StoryID,
+			formatDuration(rec.Duration()), rec.ExitCode, icon, rolledBack)
+		if i == m.logCursor {
+			line = StoryCurrentStyle.Render(line)
+		} else {
+			line = StoryPendingStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, HelpStyle.Render("(none)"))
+	}
+
+	listHeight := panelHeight - 2
+	start := 0
+	if m.logCursor >= listHeight {
+		start = m.logCursor - listHeight + 1
+	}
+	lines = lines[start:min(len(lines), start+listHeight)]
+
+	list := PanelActiveStyle.Width(logListWidth).Height(panelHeight).Render(
+		lipgloss.JoinVertical(lipgloss.Left, PanelTitleStyle.Render("Iterations"), strings.Join(lines, "\n")))
+	log := PanelStyle.Width(m.logViewport.Width + 2).Height(panelHeight).Render(m.logViewport.View())
+
+	footer := "↑/↓ select │ J/K or PgUp/PgDn scroll │ / search │ n/N next/prev match │ p log/patch │ esc close"
+	switch {
+	case m.logSearching:
+		footer = fmt.Sprintf("Search: %s█", m.logQuery)
+	case m.logQuery != "":
+		footer = fmt.Sprintf("%d matches for %q │ %s", len(m.logMatches), m.logQuery, footer)
+	}
+
+	title := " Ralph - Iteration Logs "
+	if m.logShowPatch {
+		title = " Ralph - Discarded Changes (↺ rolled back) "
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		HeaderStyle.Render(title),
+		lipgloss.JoinHorizontal(lipgloss.Top, list, log),
+		HelpStyle.Render(footer),
+	)
+}
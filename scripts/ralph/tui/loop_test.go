@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const testBranch = "ralph/test"
+
+func testStory(id string, priority int) Story {
+	return Story{ID: id, Title: "Story " + id, Priority: priority, AcceptanceCriteria: []string{"it works"}}
+}
+
+// newTestProject creates a git repository holding a Ralph directory the way
+// a project keeps it, with .runs/ ignored, and returns the PRD's path
+func newTestProject(t *testing.T, stories ...Story) string {
+	t.Helper()
+	root := t.TempDir()
+	ralphDir := filepath.Join(root, "scripts", "ralph")
+	if err := os.MkdirAll(ralphDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	prd, err := json.MarshalIndent(PRD{Project: "test", BranchName: testBranch, UserStories: stories}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(ralphDir, "prd.json"):   string(prd) + "\n",
+		filepath.Join(ralphDir, "prompt.md"):  "Work on the next story.\n",
+		filepath.Join(ralphDir, ".gitignore"): ".runs/\n.last-branch\n",
+		filepath.Join(root, "main.go"):        "package main\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "init"},
+	} {
+		if err := gitRun(root, args...); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+	return filepath.Join(ralphDir, "prd.json")
+}
+
+// newTestModel builds the TUI's model for cfg with a scripted agent
+func newTestModel(t *testing.T, cfg Config, script string) Model {
+	t.Helper()
+	cfg.fillDefaults(filepath.Dir(cfg.PRD))
+	return NewModel(cfg, writeScript(t, script))
+}
+
+// runTestLoop runs the headless loop with a scripted agent and returns its
+// exit code and the iterations it recorded
+func runTestLoop(t *testing.T, cfg Config, script string) (int, []IterationRecord) {
+	t.Helper()
+	cfg.Headless = true
+	if cfg.MaxIterations == 0 {
+		cfg.MaxIterations = 1
+	}
+	model := newTestModel(t, cfg, script)
+	var out bytes.Buffer
+	model.reporter, _ = NewReporter("json", &out)
+
+	done := make(chan int, 1)
+	go func() {
+		code, err := runHeadless(model)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- code
+	}()
+
+	var code int
+	select {
+	case code = <-done:
+	case <-time.After(20 * time.Second):
+		t.Fatalf("loop did not finish; events:\n%s", out.String())
+	}
+
+	history, err := LoadHistory(historyPath(filepath.Dir(cfg.PRD)), testBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t.Failed() {
+		t.Logf("events:\n%s", out.String())
+	}
+	return code, history
+}
+
+func TestLoopRunsIteration(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1))
+
+	code, history := runTestLoop(t, Config{PRD: prdPath}, "Working on US-001\ndone\n")
+	if code != ExitMaxIterations {
+		t.Errorf("exit code = %d, want %d", code, ExitMaxIterations)
+	}
+	if len(history) != 1 {
+		t.Fatalf("recorded %d iterations, want 1", len(history))
+	}
+	rec := history[0]
+	if rec.StoryID != "US-001" || rec.ExitCode != 0 || rec.Passed || rec.TimedOut {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if _, err := os.Stat(rec.LogPath); err != nil {
+		t.Errorf("iteration log: %v", err)
+	}
+	if branch := gitCurrentBranch(filepath.Dir(prdPath)); branch != testBranch {
+		t.Errorf("branch = %q, want %q", branch, testBranch)
+	}
+}
+
+func TestLoopTimesOutIteration(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1))
+
+	cfg := Config{PRD: prdPath, IterationTimeout: 300 * time.Millisecond}
+	code, history := runTestLoop(t, cfg, "started\n!sleep 30s\nnever printed\n")
+	if code != ExitMaxIterations {
+		t.Errorf("exit code = %d, want %d", code, ExitMaxIterations)
+	}
+	if len(history) != 1 {
+		t.Fatalf("recorded %d iterations, want 1", len(history))
+	}
+	if rec := history[0]; !rec.TimedOut || rec.Error == "" {
+		t.Errorf("iteration was not recorded as timed out: %+v", rec)
+	}
+}
+
+func TestLoopIgnoresCompletionSignalWhileStoriesFail(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1), testStory("US-002", 2))
+
+	cfg := Config{PRD: prdPath, MaxIterations: 2}
+	code, history := runTestLoop(t, cfg, "<promise>COMPLETE</promise>\n")
+	if code != ExitMaxIterations {
+		t.Errorf("exit code = %d, want %d", code, ExitMaxIterations)
+	}
+	if len(history) != 2 {
+		t.Errorf("recorded %d iterations, want the loop to go on to 2", len(history))
+	}
+}
+
+func TestLoopRollsBackFailedIteration(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1))
+
+	code, history := runTestLoop(t, Config{PRD: prdPath, Rollback: true}, "giving up\n!exit 1\n")
+	if code != ExitMaxIterations {
+		t.Errorf("exit code = %d, want %d", code, ExitMaxIterations)
+	}
+	if len(history) != 1 {
+		t.Fatalf("recorded %d iterations, want 1", len(history))
+	}
+	if rec := history[0]; !rec.RolledBack || rec.Error != "" {
+		t.Errorf("iteration was not rolled back cleanly: %+v", rec)
+	}
+}
+
+func TestLoopCountsAttemptsAcrossSessions(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1))
+	// an earlier session already spent the story's one attempt
+	earlier := IterationRecord{Session: "20060102-150405", Branch: testBranch, Iteration: 1, StoryID: "US-001", ExitCode: 1}
+	if err := AppendHistory(historyPath(filepath.Dir(prdPath)), earlier); err != nil {
+		t.Fatal(err)
+	}
+
+	code, history := runTestLoop(t, Config{PRD: prdPath, MaxAttempts: 1}, "not done yet\n")
+	if code != ExitMaxIterations {
+		t.Errorf("exit code = %d, want %d", code, ExitMaxIterations)
+	}
+	if len(history) != 1 {
+		t.Errorf("the stuck story was retried: %d iterations recorded", len(history))
+	}
+}
+
+func TestStartingAgainClearsAgentError(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1))
+	m := newTestModel(t, Config{PRD: prdPath, MaxIterations: 3}, "done\n")
+	m.agentFailed = true
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = updated.(Model)
+	if m.agentFailed || !m.processRunning || cmd == nil {
+		t.Errorf("r did not start a new iteration after an error: agentFailed=%v running=%v", m.agentFailed, m.processRunning)
+	}
+}
@@ -1,15 +1,46 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(runValidate(os.Args[2:], os.Stdout, os.Stderr))
+		case "import":
+			os.Exit(runImport(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
+	configFlag := flag.String("config", "", "config file (default: first of ralph.toml, ralph.yaml, ralph.yml in the working directory or next to the executable)")
+	prdFlag := flag.String("prd", "", "path to prd.json")
+	promptFlag := flag.String("prompt", "", "path to prompt.md (default: next to the PRD)")
+	rootFlag := flag.String("root", "", "project root the agent runs in (default: the PRD's git repository)")
+	maxIterFlag := flag.Int("max-iterations", 0, "maximum iterations (default: 1.3 × pending stories)")
+	agentFlag := flag.String("agent", "", "agent backend: opencode, command:<cmd> [args] or fake:<script> (overrides the PRD's \"agent\")")
+	autoStartFlag := flag.Bool("auto-start", false, "start the first iteration immediately")
+	headlessFlag := flag.Bool("headless", false, "run without the TUI, printing progress to stdout; exits 0 when all stories pass, 1 at max iterations, 2 on agent error")
+	formatFlag := flag.String("format", "text", "headless output format: text or json (NDJSON events)")
+	timeoutFlag := flag.Duration("timeout", 0, "kill an iteration after this long, e.g. 45m (0 disables)")
+	stallFlag := flag.Duration("stall-timeout", 0, "kill an iteration that prints no output for this long, e.g. 10m (0 disables)")
+	var checkFlags stringList
+	flag.Var(&checkFlags, "check", "command that must pass before a story the agent marks as passing is accepted (repeatable)")
+	checkTimeoutFlag := flag.Duration("check-timeout", 0, "kill a check command after this long (0 disables)")
+	rollbackFlag := flag.Bool("rollback", false, "reset the working tree after a failed iteration, saving the discarded changes as a patch under .runs/patches")
+	maxAttemptsFlag := flag.Int("max-attempts", 0, "failed iterations a story gets before it is marked stuck and skipped (0 disables)")
+	workersFlag := flag.Int("workers", 0, "run this many stories at once, each in its own git worktree (default 1)")
+	flag.Parse()
+
 	exePath, err := os.Executable()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting executable path: %v\n", err)
@@ -18,28 +49,110 @@ func main() {
 
 	exeDir := filepath.Dir(exePath)
 
-	prdPath := filepath.Join(exeDir, "prd.json")
-	promptPath := filepath.Join(exeDir, "prompt.md")
-	projectRoot := filepath.Dir(filepath.Dir(exeDir))
+	var cfg Config
+	configPath := *configFlag
+	if configPath == "" {
+		cwd, _ := os.Getwd()
+		configPath = FindConfigFile(cwd, exeDir)
+	}
+	if configPath != "" {
+		cfg, err = LoadConfigFile(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	agentSet := false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "prd":
+			cfg.PRD = *prdFlag
+		case "prompt":
+			cfg.Prompt = *promptFlag
+		case "root":
+			cfg.Root = *rootFlag
+		case "max-iterations":
+			cfg.MaxIterations = *maxIterFlag
+		case "agent":
+			cfg.Agent = *agentFlag
+			agentSet = true
+		case "auto-start":
+			cfg.AutoStart = *autoStartFlag
+		case "headless":
+			cfg.Headless = *headlessFlag
+		case "format":
+			cfg.Format = *formatFlag
+		case "timeout":
+			cfg.IterationTimeout = *timeoutFlag
+		case "stall-timeout":
+			cfg.StallTimeout = *stallFlag
+		case "check":
+			cfg.Checks = checkFlags
+		case "check-timeout":
+			cfg.CheckTimeout = *checkTimeoutFlag
+		case "rollback":
+			cfg.Rollback = *rollbackFlag
+		case "max-attempts":
+			cfg.MaxAttempts = *maxAttemptsFlag
+		case "workers":
+			cfg.Workers = *workersFlag
+		}
+	})
+	cfg.fillDefaults(exeDir)
 
-	if _, err := os.Stat(prdPath); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: prd.json not found at %s\n", prdPath)
+	if _, err := os.Stat(cfg.PRD); os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: prd.json not found at %s\n", cfg.PRD)
 		os.Exit(1)
 	}
 
-	prd, err := LoadPRD(prdPath)
+	prd, err := LoadPRD(cfg.PRD)
+	var invalid *PRDValidationError
+	if errors.As(err, &invalid) {
+		fmt.Fprintf(os.Stderr, "Error: %s does not match the PRD schema:\n", cfg.PRD)
+		for _, p := range invalid.Problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", p)
+		}
+		os.Exit(1)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading prd.json: %v\n", err)
 		os.Exit(1)
 	}
 
-	pendingCount := CountPending(prd.UserStories)
-	maxIterations := int(math.Ceil(float64(pendingCount) * 1.3))
-	if maxIterations < 1 {
-		maxIterations = 1
+	if !agentSet && prd.Agent != "" {
+		cfg.Agent = prd.Agent
+	}
+
+	runner, err := ParseAgentSpec(cfg.Agent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if cfg.MaxIterations <= 0 {
+		pendingCount := CountPending(prd.UserStories)
+		cfg.MaxIterations = int(math.Ceil(float64(pendingCount) * 1.3))
+		if cfg.MaxIterations < 1 {
+			cfg.MaxIterations = 1
+		}
 	}
 
-	model := NewModel(prdPath, promptPath, projectRoot, maxIterations)
+	model := NewModel(cfg, runner)
+
+	if cfg.Headless {
+		model.reporter, err = NewReporter(cfg.Format, os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		code, err := runHeadless(model)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
+		}
+		os.Exit(code)
+	}
 
 	p := tea.NewProgram(
 		model,
@@ -52,3 +165,15 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
-	"os/exec"
+	"fmt"
+	"path/filepath"
+	"regexp"
 	"time"
 
 	"github.com/charmbracelet/bubbles/viewport"
@@ -13,6 +15,20 @@ type Panel int
 const (
 	PanelStories Panel = iota
 	PanelOutput
+	PanelLearnings
+)
+
+// Overlay is a full-screen view drawn over the panels
+type Overlay int
+
+const (
+	OverlayNone Overlay = iota
+	OverlayArchiveConfirm
+	OverlayArchives
+	OverlayLogs
+	OverlayDiff
+	OverlayEditor
+	OverlayDeleteConfirm
 )
 
 type Model struct {
@@ -21,71 +37,266 @@ type Model struct {
 	completedCount   int
 	currentIteration int
 	maxIterations    int
+	autoStart        bool
 	currentStoryID   string
-	iterationStart   time.Time
-	storyStartTimes  map[string]time.Time
-	storyDurations   map[string]time.Duration
+	// claimedStoryID is the story the agent says it works on, which the
+	// prompt lets it choose unless the story was picked
+	claimedStoryID string
+	// iterationUsage sums the usage the agent reported this iteration
+	iterationUsage  TokenUsage
+	iterationStart  time.Time
+	storyStartTimes map[string]time.Time
+	storyDurations  map[string]time.Duration
+	history         []IterationRecord
+	session         string
 
 	processRunning bool
 	processDone    bool
-	processError   error
-	initError      error
-	runningCmd     *exec.Cmd
-
-	outputLines       []string
-	outputViewport    viewport.Model
-	storyScroll       int
-	showHelp          bool
-	searchMode        bool
-	searchQuery       string
-	prdUpdateNotif    string
-	prdUpdateNotifEnd time.Time
+	// completeSignalled is set when the agent printed the completion signal
+	// during the running iteration
+	completeSignalled bool
+	processError      error
+	initError         error
+	runningProc       AgentProcess
+	runner            AgentRunner
+	lastOutputAt      time.Time
+	killReason        string
+	quitting          bool
+	killGrace         time.Duration
+
+	paused           bool
+	stopAfterCurrent bool
+	suspended        bool
+	suspendedAt      time.Time
+	suspendedFor     time.Duration
+
+	iterationTimeout time.Duration
+	stallTimeout     time.Duration
+
+	// rollback resets the working tree after a failed iteration
+	rollback bool
+
+	// workers run stories in parallel worktrees; empty runs one story at a
+	// time in the project root
+	workers       []worker
+	focusedWorker int
+
+	// verifier is nil unless check commands are configured
+	verifier *Verifier
+	// storyMatcher spots the story the agent claims in its output, using
+	// the PRD's IDs and the configured storyPatterns
+	storyMatcher  storyMatcher
+	storyPatterns []*regexp.Regexp
+	verdicts      map[string]Verification
+	// awaitingChecks holds stories the agent marked as passing during the
+	// running iteration; they count only once the checks pass
+	awaitingChecks map[string]bool
+
+	outputLines    []outputLine
+	outputViewport viewport.Model
+	// expandTools shows the output of the agent's tool calls instead of
+	// folding it
+	expandTools    bool
+	storyScroll    int
+	showHelp       bool
+	searchMode     bool
+	searchQuery    string
+	statusNotif    string
+	statusNotifEnd time.Time
 
 	width  int
 	height int
 
 	focusedPanel Panel
+	storyCursor  int
+	// treeView lists stories as their dependency tree
+	treeView bool
 
-	prdPath     string
-	promptPath  string
-	projectRoot string
+	// maxAttempts is the default per-story attempt limit; storyAttempts
+	// counts the failed iterations in history per story, and attemptsReset
+	// holds the attempts forgiven when a stuck story was unstuck
+	maxAttempts   int
+	storyAttempts map[string]int
+	attemptsReset map[string]int
+
+	// pickedStoryID overrides GetNextStory for the next iteration
+	pickedStoryID string
+
+	overlay         Overlay
+	pendingArchive  *BranchChange
+	archives        []ArchivedRun
+	archiveCursor   int
+	archiveViewport viewport.Model
+
+	logCursor    int
+	logLines     []string
+	logViewport  viewport.Model
+	logSearching bool
+	logQuery     string
+	logMatches   []int
+	logMatchIdx  int
+	logShowPatch bool
+
+	diffStoryID     string
+	diffCommits     []Commit
+	diffCommitLines []int
+	diffViewport    viewport.Model
+
+	// form is the open story editor; pendingDelete awaits confirmation
+	form          *storyForm
+	pendingDelete string
+
+	prdPath      string
+	promptPath   string
+	projectRoot  string
+	ralphDir     string
+	progressPath string
+
+	prdWatcher *fileWatcher
+
+	// progress is progress.txt as last parsed; the Learnings panel shows it
+	// in the Output panel's place while showLearnings is set
+	progress          ProgressLog
+	progressWatcher   *fileWatcher
+	showLearnings     bool
+	learningsEntry    int
+	learningsLines    []int
+	learningsViewport viewport.Model
+	// prdError is set while prd.json cannot be loaded; the last good copy
+	// stays in use until it can
+	prdError error
 
 	msgChan chan interface{}
+
+	headless bool
+	reporter Reporter
+	// agentFailed stops the loop after an iteration error until the user
+	// starts it again
+	agentFailed bool
+	exitCode    int
 }
 
-func NewModel(prdPath, promptPath, projectRoot string, maxIterations int) Model {
+func NewModel(cfg Config, runner AgentRunner) Model {
+	prdPath := cfg.PRD
 	prd, err := LoadPRD(prdPath)
 
 	m := Model{
-		prd:              prd,
-		stories:          prd.UserStories,
-		completedCount:   CountCompleted(prd.UserStories),
-		currentIteration: 0,
-		maxIterations:    maxIterations,
-		outputLines:      []string{},
-		outputViewport:   viewport.New(80, 20),
-		focusedPanel:     PanelOutput,
-		prdPath:          prdPath,
-		promptPath:       promptPath,
-		projectRoot:      projectRoot,
-		msgChan:          make(chan interface{}, 100),
-		initError:        err,
-		storyStartTimes:  make(map[string]time.Time),
-		storyDurations:   make(map[string]time.Duration),
+		prd:               prd,
+		stories:           prd.UserStories,
+		completedCount:    CountCompleted(prd.UserStories),
+		currentIteration:  0,
+		maxIterations:     cfg.MaxIterations,
+		autoStart:         cfg.AutoStart,
+		outputLines:       []outputLine{},
+		outputViewport:    viewport.New(80, 20),
+		focusedPanel:      PanelOutput,
+		prdPath:           prdPath,
+		prdWatcher:        newFileWatcher(prdPath),
+		promptPath:        cfg.Prompt,
+		projectRoot:       cfg.Root,
+		ralphDir:          filepath.Dir(prdPath),
+		progressPath:      filepath.Join(filepath.Dir(prdPath), progressFileName),
+		archiveViewport:   viewport.New(80, 20),
+		logViewport:       viewport.New(80, 20),
+		diffViewport:      viewport.New(80, 20),
+		learningsViewport: viewport.New(80, 20),
+		learningsEntry:    -1,
+		runner:            runner,
+		iterationTimeout:  cfg.IterationTimeout,
+		stallTimeout:      cfg.StallTimeout,
+		killGrace:         cfg.KillGrace,
+		rollback:          cfg.Rollback,
+		maxAttempts:       cfg.MaxAttempts,
+		storyAttempts:     make(map[string]int),
+		attemptsReset:     make(map[string]int),
+		msgChan:           make(chan interface{}, 100),
+		initError:         err,
+		storyStartTimes:   make(map[string]time.Time),
+		storyDurations:    make(map[string]time.Duration),
+		verdicts:          make(map[string]Verification),
+		awaitingChecks:    make(map[string]bool),
+		session:           time.Now().Format("20060102-150405"),
+	}
+
+	m.progressWatcher = newFileWatcher(m.progressPath)
+	m.progress, _ = LoadProgress(m.progressPath)
+
+	if err == nil {
+		history, histErr := LoadHistory(historyPath(m.ralphDir), prd.BranchName)
+		if histErr != nil {
+			m.processError = histErr
+		}
+		m.history = history
+		m.storyDurations = storyDurationsFromHistory(history)
+		m.verdicts = verdictsFromHistory(history)
+		m.storyAttempts = attemptsFromHistory(history)
+	}
+
+	if err == nil && cfg.Workers > 1 {
+		if layoutErr := checkWorktreeLayout(cfg.Root, prdPath, prd.BranchName); layoutErr != nil {
+			m.processError = fmt.Errorf("%w; running one story at a time", layoutErr)
+		} else {
+			m.workers = newWorkers(cfg.Workers)
+		}
+	}
+
+	// the patterns were checked when the config was loaded
+	m.storyPatterns, _ = CompileStoryPatterns(cfg.StoryPatterns)
+	m.storyMatcher = newStoryMatcher(m.stories, m.storyPatterns)
+
+	if len(cfg.Checks) > 0 {
+		m.verifier = &Verifier{Checks: cfg.Checks, Timeout: cfg.CheckTimeout, Dir: cfg.Root}
+	}
+
+	if err == nil && len(m.stories) > 0 && m.completedCount == len(m.stories) {
+		m.processDone = true
+	}
+
+	if m.killGrace <= 0 {
+		m.killGrace = defaultKillGrace
+	}
+
+	if cfg.Headless {
+		m.headless = true
+		m.autoStart = true
+	}
+
+	if err == nil {
+		m.pendingArchive = DetectBranchChange(m.ralphDir, prd)
+		if m.pendingArchive != nil && !m.headless {
+			m.overlay = OverlayArchiveConfirm
+		}
 	}
 
 	if err != nil {
-		m.outputLines = append(m.outputLines, "ERROR: Failed to load PRD file: "+err.Error())
-		m.outputLines = append(m.outputLines, "Path: "+prdPath)
-		m.outputViewport.SetContent(string(m.outputLines[0]) + "\n" + m.outputLines[1])
+		m.outputLines = append(m.outputLines,
+			outputLine{Text: "ERROR: Failed to load PRD file: " + err.Error()},
+			outputLine{Text: "Path: " + prdPath})
+		m.outputViewport.SetContent(renderOutputLines(m.outputLines, false))
 	}
 
 	return m
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
-		watchPRDCmd(m.prdPath),
+	cmds := []tea.Cmd{
+		watchPRDCmd(m.prdWatcher),
+		watchProgressCmd(m.progressWatcher),
+		listenForOutputCmd(m.msgChan),
 		tickCmd(),
-	)
+	}
+	switch {
+	case m.initError != nil:
+	case m.pendingArchive != nil && m.headless:
+		// ralph.sh behaviour: archive the previous branch's run unasked
+		cmds = append(cmds, archiveRunCmd(m.prdPath, m.progressPath, m.pendingArchive.Previous, m.prd.BranchName))
+	case m.pendingArchive != nil:
+		// wait for the user to answer the archive prompt
+	default:
+		cmds = append(cmds, bootstrapRunCmd(m.ralphDir, m.progressPath, m.prd.BranchName))
+		if m.autoStart {
+			cmds = append(cmds, func() tea.Msg { return AutoStartMsg{} })
+		}
+	}
+	return tea.Batch(cmds...)
 }
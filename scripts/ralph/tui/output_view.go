@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+)
+
+// outputLine is a line of the Output panel or a worker's pane. A tool call
+// keeps its output as Detail, which is folded away unless tool blocks are
+// expanded.
+type outputLine struct {
+	Text   string
+	Detail []string
+	Failed bool
+}
+
+// eventLine is the line shown for a message from the agent
+func eventLine(msg OutputLineMsg) outputLine {
+	return outputLine{
+		Text:   formatTimestamp(msg.Timestamp) + " " + msg.Line,
+		Detail: msg.Event.Detail,
+		Failed: msg.Event.Failed,
+	}
+}
+
+// renderOutputLines joins lines for a viewport, with each tool call's output
+// either indented below it or folded into a count
+func renderOutputLines(lines []outputLine, expanded bool) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		text := line.Text
+		if line.Failed {
+			text = StoryRejectedStyle.Render(text)
+		}
+		b.WriteString(text)
+		if len(line.Detail) == 0 {
+			continue
+		}
+		if !expanded {
+			noun := "lines"
+			if len(line.Detail) == 1 {
+				noun = "line"
+			}
+			b.WriteString(HelpStyle.Render(fmt.Sprintf("  (+%d %s, c to expand)", len(line.Detail), noun)))
+			continue
+		}
+		for _, d := range line.Detail {
+			b.WriteString("\n" + HelpStyle.Render("           │ "+d))
+		}
+	}
+	return b.String()
+}
+
+// setOutputContent renders lines into vp, staying at the bottom if vp was
+// there
+func setOutputContent(vp *viewport.Model, lines []outputLine, expanded bool) {
+	follow := vp.AtBottom()
+	vp.SetContent(renderOutputLines(lines, expanded))
+	if follow {
+		vp.GotoBottom()
+	}
+}
+
+// appendOutputLine adds a line to the Output panel
+func (m *Model) appendOutputLine(line outputLine) {
+	m.outputLines = append(m.outputLines, line)
+	m.outputViewport.SetContent(renderOutputLines(m.outputLines, m.expandTools))
+	m.outputViewport.GotoBottom()
+}
+
+// appendOutput adds a timestamped line to the Output panel
+func (m *Model) appendOutput(line string) {
+	m.appendOutputLine(outputLine{Text: formatTimestamp(time.Now()) + " " + line})
+}
+
+// toggleToolBlocks expands or folds the output of every tool call
+func (m *Model) toggleToolBlocks() {
+	m.expandTools = !m.expandTools
+	setOutputContent(&m.outputViewport, m.outputLines, m.expandTools)
+	for i := range m.workers {
+		w := &m.workers[i]
+		w.ExpandTools = m.expandTools
+		setOutputContent(&w.Viewport, w.Lines, w.ExpandTools)
+	}
+}
@@ -11,6 +11,7 @@ type PRD struct {
 	Project     string  `json:"project"`
 	BranchName  string  `json:"branchName"`
 	Description string  `json:"description"`
+	Agent       string  `json:"agent,omitempty"`
 	UserStories []Story `json:"userStories"`
 }
 
@@ -23,15 +24,24 @@ type Story struct {
 	Passes             bool     `json:"passes"`
 	Notes              string   `json:"notes"`
 	AcceptanceCriteria []string `json:"acceptanceCriteria"`
+	// DependsOn lists stories that must pass before this one is started
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// MaxAttempts overrides the configured attempt limit for this story
+	MaxAttempts int `json:"maxAttempts,omitempty"`
 }
 
-// LoadPRD reads and parses the PRD JSON file
+// LoadPRD reads and parses the PRD JSON file, rejecting one that does not
+// match the schema (see ValidatePRD)
 func LoadPRD(path string) (PRD, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return PRD{}, err
 	}
 
+	if problems := ValidatePRD(data); len(problems) > 0 {
+		return PRD{}, &PRDValidationError{Problems: problems}
+	}
+
 	var prd PRD
 	if err := json.Unmarshal(data, &prd); err != nil {
 		return PRD{}, err
@@ -60,10 +70,11 @@ func CountPending(stories []Story) int {
 	return len(stories) - CountCompleted(stories)
 }
 
-// GetNextStory returns the next incomplete story by priority
+// GetNextStory returns the next incomplete story by priority whose
+// dependencies all pass
 func GetNextStory(stories []Story) *Story {
 	for i := range stories {
-		if !stories[i].Passes {
+		if !stories[i].Passes && DependenciesMet(stories, stories[i]) {
 			return &stories[i]
 		}
 	}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// jsonObject is a JSON object that remembers its key order and keeps values
+// it does not need to understand as raw JSON
+type jsonObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func (o *jsonObject) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return errors.New("expected a JSON object")
+	}
+
+	o.keys = nil
+	o.values = make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		if _, dup := o.values[key]; !dup {
+			o.keys = append(o.keys, key)
+		}
+		o.values[key] = value
+	}
+	_, err = dec.Token()
+	return err
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := marshalJSON(key)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(o.values[key])
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// Get decodes the value stored under key into v. A missing key leaves v as is.
+func (o *jsonObject) Get(key string, v any) error {
+	raw, ok := o.values[key]
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// Set stores v under key, appending the key if it is new
+func (o *jsonObject) Set(key string, v any) error {
+	raw, err := marshalJSON(v)
+	if err != nil {
+		return err
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = raw
+	return nil
+}
+
+// Delete removes key from the object
+func (o *jsonObject) Delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// marshalJSON is json.Marshal without HTML escaping, so "<" and "&" in notes
+// and criteria survive a round trip unchanged
+func marshalJSON(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
+
+// PRDDocument is prd.json loaded for editing. Fields the PRD and Story
+// structs do not know about, key order and indentation are kept on save.
+type PRDDocument struct {
+	root    jsonObject
+	Stories []*jsonObject
+
+	indent       string
+	finalNewline bool
+}
+
+// LoadPRDDocument reads prd.json for editing
+func LoadPRDDocument(path string) (*PRDDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePRDDocument(data)
+}
+
+// ParsePRDDocument parses prd.json content for editing
+func ParsePRDDocument(data []byte) (*PRDDocument, error) {
+	doc := &PRDDocument{
+		indent:       detectIndent(data),
+		finalNewline: bytes.HasSuffix(data, []byte("\n")),
+	}
+	if err := json.Unmarshal(data, &doc.root); err != nil {
+		return nil, err
+	}
+	if err := doc.root.Get("userStories", &doc.Stories); err != nil {
+		return nil, fmt.Errorf("userStories: %w", err)
+	}
+	return doc, nil
+}
+
+// Story returns the story object with the given ID, or nil
+func (d *PRDDocument) Story(id string) *jsonObject {
+	for _, s := range d.Stories {
+		var storyID string
+		if s.Get("id", &storyID) == nil && storyID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Bytes renders the document in the indentation it was loaded with
+func (d *PRDDocument) Bytes() ([]byte, error) {
+	stories := d.Stories
+	if stories == nil {
+		stories = []*jsonObject{}
+	}
+	if err := d.root.Set("userStories", stories); err != nil {
+		return nil, err
+	}
+
+	compact, err := marshalJSON(d.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact, "", d.indent); err != nil {
+		return nil, err
+	}
+	if d.finalNewline {
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// Save writes the document to path atomically. A document that no longer
+// matches the schema is not written.
+func (d *PRDDocument) Save(path string) error {
+	data, err := d.Bytes()
+	if err != nil {
+		return err
+	}
+	if problems := ValidatePRD(data); len(problems) > 0 {
+		return &PRDValidationError{Problems: problems}
+	}
+	return writeFileAtomic(path, data)
+}
+
+// detectIndent returns the indentation of the first indented line, falling
+// back to a tab
+func detectIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "\t"
+}
+
+// writeFileAtomic replaces path with data via a temporary file in the same
+// directory, so readers never see a half-written file
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestPRDDocumentRoundTripsRepoPRD(t *testing.T) {
+	data, err := os.ReadFile("../prd.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ParsePRDDocument(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Errorf("saving prd.json unchanged rewrites it:\n%s", out)
+	}
+}
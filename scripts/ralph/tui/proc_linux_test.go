@@ -0,0 +1,60 @@
+//go:build linux
+
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// alive reports whether pid is a live process, not gone or a zombie
+func alive(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestTerminateKillsChildThatIgnoresSIGTERM(t *testing.T) {
+	// the shell dies on SIGTERM; its child ignores it, reports its PID once
+	// it does and lets go of the pipes
+	child := `trap "" TERM; echo $$ >&3; exec sleep 30 3>&-`
+	runner := &CommandRunner{Path: "sh", Args: []string{"-c", `sh -c '` + child + `' 3>&1 >/dev/null 2>&1 & wait`}}
+	proc, err := runner.Start("", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go io.Copy(io.Discard, proc.Stderr())
+
+	var line [32]byte
+	n, _ := proc.Stdout().Read(line[:])
+	pid, err := strconv.Atoi(strings.TrimSpace(string(line[:n])))
+	if err != nil {
+		t.Fatalf("child pid: %v", err)
+	}
+	go io.Copy(io.Discard, proc.Stdout())
+
+	if err := proc.Terminate(200 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	proc.Wait()
+	if !alive(pid) {
+		t.Fatal("child exited on SIGTERM; the test needs one that ignores it")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatal("child outlived the grace period")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup has no graceful variant without unix signals
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+var errSuspendUnsupported = errors.New("suspending agents is not supported on this platform")
+
+func suspendProcessGroup(cmd *exec.Cmd) error {
+	return errSuspendUnsupported
+}
+
+func resumeProcessGroup(cmd *exec.Cmd) error {
+	return errSuspendUnsupported
+}
+
+func shellCommand(line string) *exec.Cmd {
+	return exec.Command("cmd", "/C", line)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+const patternsHeading = "Codebase Patterns"
+
+// ProgressEntry is one "## <date> - <story>" section of progress.txt
+type ProgressEntry struct {
+	Heading string
+	StoryID string
+	Body    string
+}
+
+// ProgressLog is progress.txt split into its sections
+type ProgressLog struct {
+	// Patterns is the body of the "## Codebase Patterns" section
+	Patterns string
+	Entries  []ProgressEntry
+}
+
+// LoadProgress reads and parses progress.txt. A missing file is an empty log.
+func LoadProgress(path string) (ProgressLog, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ProgressLog{}, nil
+	}
+	if err != nil {
+		return ProgressLog{}, err
+	}
+	return ParseProgress(string(data)), nil
+}
+
+// ParseProgress splits a progress log on its "## " headings. Preamble such as
+// the "# Ralph Progress Log" title and the lines after it, before the next
+// "## " heading, is ignored, as are the "---" separators between entries.
+func ParseProgress(text string) ProgressLog {
+	var log ProgressLog
+	var heading string
+	var body []string
+	inSection := false
+
+	flush := func() {
+		if !inSection {
+			return
+		}
+		content := strings.TrimSpace(strings.Join(body, "\n"))
+		if heading == patternsHeading {
+			log.Patterns = content
+		} else {
+			log.Entries = append(log.Entries, ProgressEntry{
+				Heading: heading,
+				StoryID: progressStoryID(heading),
+				Body:    content,
+			})
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if title, ok := strings.CutPrefix(line, "## "); ok {
+			flush()
+			heading = strings.TrimSpace(title)
+			body = nil
+			inSection = true
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			// a top-level heading starts preamble again
+			flush()
+			inSection = false
+			continue
+		}
+		if strings.TrimSpace(line) == "---" {
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+
+	return log
+}
+
+// StoryEntries returns the entries written for storyID, oldest first
+func (l ProgressLog) StoryEntries(storyID string) []ProgressEntry {
+	var entries []ProgressEntry
+	for _, e := range l.Entries {
+		if e.StoryID == storyID {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Recent returns the last n entries, oldest first
+func (l ProgressLog) Recent(n int) []ProgressEntry {
+	if n >= len(l.Entries) {
+		return l.Entries
+	}
+	return l.Entries[len(l.Entries)-n:]
+}
+
+// progressStoryID extracts the story from a "<date> - <story>" heading
+func progressStoryID(heading string) string {
+	idx := strings.LastIndex(heading, " - ")
+	if idx < 0 {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimSpace(heading[idx+3:]), " ")
+	return id
+}
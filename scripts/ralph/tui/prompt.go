@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	// failureTailLines is how much of a failed iteration's output is fed back
+	failureTailLines = 60
+	// recentProgressEntries is how many progress.txt entries .Progress holds
+	recentProgressEntries = 3
+)
+
+// PromptData is what prompt.md can reference as a text/template, e.g.
+//
+//	{{with .Story}}Work on {{.ID}}: {{.Title}}
+//	{{range .AcceptanceCriteria}}- {{.}}
+//	{{end}}{{end}}
+//	{{with .PreviousFailure}}The last attempt failed with:
+//	{{.}}{{end}}
+//
+// A prompt without template actions is sent unchanged.
+type PromptData struct {
+	PRD           PRD
+	Story         *Story // nil once every story passes
+	Iteration     int
+	MaxIterations int
+	// Pinned is set when the story was picked by hand rather than by priority
+	Pinned bool
+	// PreviousFailure is the tail of the output of the story's last attempt,
+	// when that attempt did not make it pass
+	PreviousFailure string
+	// Patterns is the "Codebase Patterns" section of progress.txt
+	Patterns string
+	// Progress holds the most recent progress.txt entries, StoryProgress
+	// every entry written for Story
+	Progress      []ProgressEntry
+	StoryProgress []ProgressEntry
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"indent": func(n int, s string) string {
+		pad := strings.Repeat(" ", n)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+}
+
+// storyAction finds a template action using .Story, but not .StoryProgress
+var storyAction = regexp.MustCompile(`\.Story\b`)
+
+// namesStory reports whether a prompt template tells the agent its story
+// itself, so the loop need not append it
+func namesStory(text string) bool {
+	return strings.Contains(text, "{{") && storyAction.MatchString(text)
+}
+
+// RenderPrompt executes the prompt template with data
+func RenderPrompt(text string, data PromptData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("prompt template: %w", err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("prompt template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// buildPromptData gathers the template context for an iteration
+func buildPromptData(spec iterationSpec) PromptData {
+	data := PromptData{
+		Iteration:     spec.Iteration,
+		MaxIterations: spec.MaxIterations,
+		Pinned:        spec.Pinned,
+	}
+
+	if prd, err := LoadPRD(spec.PRDPath); err == nil {
+		data.PRD = prd
+		data.Story = GetStoryByID(prd.UserStories, spec.StoryID)
+	}
+
+	if progress, err := LoadProgress(spec.ProgressPath); err == nil {
+		data.Patterns = progress.Patterns
+		data.Progress = progress.Recent(recentProgressEntries)
+		data.StoryProgress = progress.StoryEntries(spec.StoryID)
+	}
+
+	if spec.PreviousLogPath != "" {
+		data.PreviousFailure = logTail(spec.PreviousLogPath, failureTailLines)
+	}
+
+	return data
+}
+
+// logTail returns the last n lines of an iteration log without their
+// timestamps
+func logTail(path string, n int) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "[") {
+			if _, rest, ok := strings.Cut(line, "] "); ok {
+				line = rest
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestShippedPromptTemplate renders the prompt.md Ralph ships with, for an
+// iteration with a story and for one without
+func TestShippedPromptTemplate(t *testing.T) {
+	text, err := os.ReadFile("../prompt.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	story := Story{ID: "US-007", Title: "Export orders", Description: "As an owner I want a CSV.", AcceptanceCriteria: []string{"CSV has a header", "Typecheck passes"}}
+	prompt, err := RenderPrompt(string(text), PromptData{
+		Story:           &story,
+		PreviousFailure: "error: missing header",
+		Patterns:        "- Use cn() for classes",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"user story **US-007**", "**US-007: Export orders**", "As an owner I want a CSV.", "- CSV has a header\n- Typecheck passes\n", "error: missing header", "- Use cn() for classes"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt misses %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "highest priority") {
+		t.Errorf("prompt still asks the agent to pick a story:\n%s", prompt)
+	}
+
+	prompt, err = RenderPrompt(string(text), PromptData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unwanted := range []string{"Your Story", "Previous Attempt", "Codebase Patterns (from"} {
+		if strings.Contains(prompt, unwanted) {
+			t.Errorf("prompt without a story has %q:\n%s", unwanted, prompt)
+		}
+	}
+	if !strings.Contains(prompt, "Pick the **highest priority** user story") {
+		t.Errorf("prompt without a story does not say which to pick:\n%s", prompt)
+	}
+}
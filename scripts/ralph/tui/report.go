@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types emitted in headless mode
+const (
+	EventRunStart       = "run_start"
+	EventIterationStart = "iteration_start"
+	EventOutput         = "output"
+	EventIterationEnd   = "iteration_end"
+	EventTimeout        = "iteration_timeout"
+	EventStoryPassed    = "story_passed"
+	EventStoryStuck     = "story_stuck"
+	EventStoryMismatch  = "story_mismatch"
+	EventVerification   = "verification"
+	EventArchived       = "archived"
+	EventError          = "error"
+	EventRunEnd         = "run_end"
+)
+
+// Event is a single structured progress event
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Iteration int       `json:"iteration,omitempty"`
+	StoryID   string    `json:"storyId,omitempty"`
+	Worker    int       `json:"worker,omitempty"`
+	Line      string    `json:"line,omitempty"`
+	// Kind, Tool, Path and Command describe output the agent produced
+	// through a tool rather than as text
+	Kind      string `json:"kind,omitempty"`
+	Tool      string `json:"tool,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Command   string `json:"command,omitempty"`
+	ExitCode  *int   `json:"exitCode,omitempty"`
+	Duration  string `json:"duration,omitempty"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	Message   string `json:"message,omitempty"`
+	// Usage is what the iteration, or the whole run, cost
+	Usage *TokenUsage `json:"usage,omitempty"`
+}
+
+// Reporter receives progress events while running headless
+type Reporter interface {
+	Report(Event)
+}
+
+// NewReporter returns a reporter for the given format ("text" or "json")
+func NewReporter(format string, w io.Writer) (Reporter, error) {
+	switch format {
+	case "", "text":
+		return &textReporter{w: w}, nil
+	case "json", "ndjson":
+		return &jsonReporter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want text or json)", format)
+	}
+}
+
+// jsonReporter writes one JSON object per line
+type jsonReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (r *jsonReporter) Report(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enc.Encode(ev)
+}
+
+// textReporter writes plain progress lines, similar to ralph.sh
+type textReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (r *textReporter) Report(ev Event) {
+	var line string
+	switch ev.Type {
+	case EventRunStart:
+		line = fmt.Sprintf("Ralph: %s (%d/%d stories complete)", ev.Message, ev.Completed, ev.Total)
+	case EventIterationStart:
+		line = fmt.Sprintf("%s Iteration %d - %s %s", strings.Repeat("═", 3), ev.Iteration, ev.StoryID, strings.Repeat("═", 3))
+	case EventOutput:
+		line = ev.Line
+	case EventIterationEnd:
+		line = fmt.Sprintf("Iteration %d finished (exit %d, %s%s)", ev.Iteration, derefInt(ev.ExitCode), ev.Duration, usageSuffix(ev.Usage))
+	case EventTimeout:
+		line = fmt.Sprintf("Iteration %d killed: %s", ev.Iteration, ev.Message)
+	case EventStoryPassed:
+		line = fmt.Sprintf("✓ %s passes (%d/%d)", ev.StoryID, ev.Completed, ev.Total)
+	case EventStoryStuck:
+		line = "✗ " + ev.Message
+	case EventStoryMismatch:
+		line = "⚠ " + ev.Message
+	case EventVerification:
+		line = "Verification: " + ev.Message
+	case EventArchived:
+		line = "Archived previous run to " + ev.Message
+	case EventError:
+		line = "Error: " + ev.Message
+	case EventRunEnd:
+		line = fmt.Sprintf("Done: %s (%d/%d stories complete, exit %d%s)", ev.Message, ev.Completed, ev.Total, derefInt(ev.ExitCode), usageSuffix(ev.Usage))
+	default:
+		line = ev.Message
+	}
+
+	if ev.Worker > 0 {
+		line = fmt.Sprintf("[w%d] %s", ev.Worker, line)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintln(r.w, formatTimestamp(ev.Time)+" "+line)
+}
+
+func derefInt(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+func usageSuffix(u *TokenUsage) string {
+	if u == nil {
+		return ""
+	}
+	return ", " + u.String()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// attempts counts the iterations on storyID that did not make it pass,
+// earlier sessions on the branch included, since the story was last unstuck
+func (m Model) attempts(storyID string) int {
+	return m.storyAttempts[storyID] - m.attemptsReset[storyID]
+}
+
+// maxAttemptsFor is the story's own limit, falling back to the configured one
+func (m Model) maxAttemptsFor(story Story) int {
+	if story.MaxAttempts > 0 {
+		return story.MaxAttempts
+	}
+	return m.maxAttempts
+}
+
+// stuck reports whether a pending story has used up its attempts
+func (m Model) stuck(story Story) bool {
+	limit := m.maxAttemptsFor(story)
+	return limit > 0 && !story.Passes && m.attempts(story.ID) >= limit
+}
+
+// stuckStories lists the IDs of the stories the loop has given up on
+func (m Model) stuckStories() []string {
+	var ids []string
+	for _, s := range m.stories {
+		if m.stuck(s) {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
+
+// nextStory is GetNextStory without the stories that are stuck
+func (m Model) nextStory() *Story {
+	for i := range m.stories {
+		s := m.stories[i]
+		if !s.Passes && DependenciesMet(m.stories, s) && !m.stuck(s) {
+			return &m.stories[i]
+		}
+	}
+	return nil
+}
+
+// noteAttempt reports a story that has just used up its attempts
+func (m *Model) noteAttempt(worker int, storyID string) {
+	story := GetStoryByID(m.stories, storyID)
+	if story == nil || !m.stuck(*story) || m.attempts(storyID) != m.maxAttemptsFor(*story) {
+		return
+	}
+	msg := fmt.Sprintf("%s is stuck after %d attempts; moving on", storyID, m.attempts(storyID))
+	m.appendOutputTo(worker, "⚠ "+msg+" (u to retry it)")
+	m.report(Event{Type: EventStoryStuck, StoryID: storyID, Worker: worker, Message: msg})
+}
+
+// unstick gives a stuck story a fresh set of attempts
+func (m *Model) unstick(story Story) {
+	if !m.stuck(story) {
+		return
+	}
+	m.attemptsReset[story.ID] += m.attempts(story.ID)
+	m.notify("↻ " + story.ID + " will be retried")
+}
+
+// stuckSummary explains why the loop stopped with stories left
+func (m Model) stuckSummary() string {
+	return "stuck: " + strings.Join(m.stuckStories(), ", ")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const patchesDirName = "patches"
+
+// rollbackSnapshot records the state an iteration started from so a failed
+// attempt can be undone
+type rollbackSnapshot struct {
+	dir  string
+	head string
+	// keep are ralph's own files (prd.json, progress.txt); their content at
+	// rollback time survives so notes and learnings are not lost
+	keep []string
+	// runsDir holds logs and history, which must survive even when the
+	// project does not ignore them
+	runsDir string
+}
+
+// takeRollbackSnapshot returns nil, with the reason, when the working tree
+// cannot be restored safely: outside git, before the first commit, or with
+// uncommitted changes of the user's that a reset would destroy
+func takeRollbackSnapshot(dir, runsDir string, keep ...string) (*rollbackSnapshot, string) {
+	head := gitHead(dir)
+	if head == "" {
+		return nil, "not a git repository with commits"
+	}
+
+	s := &rollbackSnapshot{dir: dir, head: head, keep: keep, runsDir: runsDir}
+	status, err := gitOutput(dir, append([]string{"status", "--porcelain"}, s.pathspec(keep...)...)...)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if status != "" {
+		return nil, "working tree has uncommitted changes"
+	}
+
+	return s, ""
+}
+
+// pathspec selects the whole tree except the runs directory and the given
+// paths. Ignored paths are left out of it: git already skips them, and
+// git add refuses a pathspec naming them.
+func (s *rollbackSnapshot) pathspec(exclude ...string) []string {
+	spec := []string{"--", "."}
+	for _, path := range append([]string{s.runsDir}, exclude...) {
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil || gitIgnored(s.dir, rel) {
+			continue
+		}
+		spec = append(spec, ":(exclude)"+filepath.ToSlash(rel))
+	}
+	return spec
+}
+
+// Restore saves everything the iteration changed — commits, edits and new
+// files — as a patch at patchPath, then resets the working tree to the
+// snapshot
+func (s *rollbackSnapshot) Restore(patchPath string) error {
+	kept := make(map[string][]byte)
+	for _, path := range s.keep {
+		if data, err := os.ReadFile(path); err == nil {
+			kept[path] = data
+		}
+	}
+
+	// intent-to-add makes new files show up in the diff
+	if err := gitRun(s.dir, append([]string{"add", "--all", "--intent-to-add"}, s.pathspec(s.keep...)...)...); err != nil {
+		return err
+	}
+	patch, err := gitRawOutput(s.dir, append([]string{"diff", "--binary", s.head}, s.pathspec(s.keep...)...)...)
+	if err != nil {
+		return fmt.Errorf("git diff: %w", err)
+	}
+	if patch != "" {
+		if err := os.MkdirAll(filepath.Dir(patchPath), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(patchPath, []byte(patch), 0o644); err != nil {
+			return err
+		}
+	}
+
+	if err := gitRun(s.dir, "reset", "--hard", s.head); err != nil {
+		return err
+	}
+	if err := gitRun(s.dir, append([]string{"clean", "-fd"}, s.pathspec(s.keep...)...)...); err != nil {
+		return err
+	}
+
+	for path, data := range kept {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchPath names the patch of a rolled back iteration like its log
+func patchPath(ralphDir string, iteration int, storyID string, start time.Time) string {
+	name := fmt.Sprintf("%s-iter%d", start.Format("20060102-150405"), iteration)
+	if storyID != "" {
+		name += "-" + sanitizeFileName(storyID)
+	}
+	return filepath.Join(ralphDir, runsDirName, patchesDirName, name+".patch")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRollbackRestoreWithIgnoredRunsDir(t *testing.T) {
+	prdPath := newTestProject(t, testStory("US-001", 1))
+	ralphDir := filepath.Dir(prdPath)
+	root := filepath.Dir(filepath.Dir(ralphDir))
+	runsDir := filepath.Join(ralphDir, runsDirName)
+	if err := os.MkdirAll(runsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	progress := filepath.Join(ralphDir, progressFileName)
+	lastBranch := filepath.Join(ralphDir, lastBranchFileName)
+	if err := os.WriteFile(lastBranch, []byte(testBranch+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// ends in a blank line, which the patch keeps as context
+	blankEnded := filepath.Join(root, "notes.txt")
+	if err := os.WriteFile(blankEnded, []byte("first\nsecond\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "notes.txt"}, {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "notes"}} {
+		if err := gitRun(root, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot, reason := takeRollbackSnapshot(root, runsDir, prdPath, progress, lastBranch)
+	if snapshot == nil {
+		t.Fatalf("no snapshot: %s", reason)
+	}
+
+	// the iteration edits a file, adds one and takes notes
+	writes := map[string]string{
+		filepath.Join(root, "main.go"):     "package main\n\nfunc main() {}\n",
+		filepath.Join(root, "new.go"):      "package main\n",
+		blankEnded:                         "FIRST\nsecond\n\n",
+		progress:                           "learned something\n",
+		filepath.Join(runsDir, "iter.log"): "log\n",
+	}
+	for path, content := range writes {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	patch := filepath.Join(runsDir, patchesDirName, "iter.patch")
+	if err := snapshot.Restore(patch); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(root, "main.go")); string(data) != "package main\n" {
+		t.Errorf("main.go was not restored: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "new.go")); !os.IsNotExist(err) {
+		t.Errorf("new.go was not removed: %v", err)
+	}
+	for path, want := range map[string]string{progress: "learned something\n", lastBranch: testBranch + "\n"} {
+		if data, _ := os.ReadFile(path); string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), data, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(runsDir, "iter.log")); err != nil {
+		t.Errorf("runs directory was not kept: %v", err)
+	}
+	data, err := os.ReadFile(patch)
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	for _, file := range []string{"main.go", "new.go", "notes.txt"} {
+		if !bytes.Contains(data, []byte("b/"+file)) {
+			t.Errorf("patch misses %s:\n%s", file, data)
+		}
+	}
+	if err := gitRun(root, "apply", "--check", patch); err != nil {
+		t.Errorf("saved patch does not apply: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StoryEdit holds the fields of a story the editor can change
+type StoryEdit struct {
+	ID                 string
+	Title              string
+	Description        string
+	Priority           int
+	AcceptanceCriteria []string
+	DependsOn          []string
+	Notes              string
+}
+
+// storyEditOf returns the editable fields of story
+func storyEditOf(story Story) StoryEdit {
+	return StoryEdit{
+		ID:                 story.ID,
+		Title:              story.Title,
+		Description:        story.Description,
+		Priority:           story.Priority,
+		AcceptanceCriteria: story.AcceptanceCriteria,
+		DependsOn:          story.DependsOn,
+		Notes:              story.Notes,
+	}
+}
+
+// editPRD loads prd.json, applies edit and saves it. Save refuses a
+// document that breaks the schema, so an edit cannot corrupt the file.
+func editPRD(path string, edit func(doc *PRDDocument) error) error {
+	doc, err := LoadPRDDocument(path)
+	if err != nil {
+		return err
+	}
+	if err := edit(doc); err != nil {
+		return err
+	}
+	return doc.Save(path)
+}
+
+// SaveStory writes e over the story originalID, or adds it as a new story
+// when originalID is empty. Renaming a story updates the dependsOn entries
+// that point at it.
+func SaveStory(path, originalID string, e StoryEdit) error {
+	return editPRD(path, func(doc *PRDDocument) error {
+		story := doc.Story(originalID)
+		if originalID == "" {
+			story = &jsonObject{values: map[string]json.RawMessage{}}
+			doc.Stories = append(doc.Stories, story)
+		} else if story == nil {
+			return fmt.Errorf("story %s no longer exists", originalID)
+		}
+
+		criteria := e.AcceptanceCriteria
+		if criteria == nil {
+			criteria = []string{}
+		}
+		fields := []struct {
+			key   string
+			value any
+		}{
+			{"id", e.ID},
+			{"title", e.Title},
+			{"description", e.Description},
+			{"acceptanceCriteria", criteria},
+			{"priority", e.Priority},
+		}
+		for _, f := range fields {
+			if err := story.Set(f.key, f.value); err != nil {
+				return err
+			}
+		}
+		if originalID == "" {
+			if err := story.Set("passes", false); err != nil {
+				return err
+			}
+		}
+		if err := story.Set("notes", e.Notes); err != nil {
+			return err
+		}
+		if len(e.DependsOn) > 0 {
+			if err := story.Set("dependsOn", e.DependsOn); err != nil {
+				return err
+			}
+		} else {
+			story.Delete("dependsOn")
+		}
+
+		if originalID != "" && e.ID != originalID {
+			return renameDependency(doc, originalID, e.ID)
+		}
+		return nil
+	})
+}
+
+// renameDependency points dependsOn entries naming from at to instead
+func renameDependency(doc *PRDDocument, from, to string) error {
+	for _, story := range doc.Stories {
+		var deps []string
+		if err := story.Get("dependsOn", &deps); err != nil {
+			return err
+		}
+		changed := false
+		for i, dep := range deps {
+			if dep == from {
+				deps[i] = to
+				changed = true
+			}
+		}
+		if changed {
+			if err := story.Set("dependsOn", deps); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteStory removes a story no other story depends on
+func DeleteStory(path, id string) error {
+	return editPRD(path, func(doc *PRDDocument) error {
+		idx := -1
+		var dependents []string
+		for i, story := range doc.Stories {
+			var storyID string
+			var deps []string
+			story.Get("id", &storyID)
+			story.Get("dependsOn", &deps)
+			if storyID == id {
+				idx = i
+			}
+			for _, dep := range deps {
+				if dep == id {
+					dependents = append(dependents, storyID)
+				}
+			}
+		}
+		switch {
+		case idx < 0:
+			return fmt.Errorf("story %s no longer exists", id)
+		case len(dependents) > 0:
+			return fmt.Errorf("%s is needed by %s", id, strings.Join(dependents, ", "))
+		}
+		doc.Stories = append(doc.Stories[:idx], doc.Stories[idx+1:]...)
+		return nil
+	})
+}
+
+// ToggleStoryPasses flips a story's passes flag
+func ToggleStoryPasses(path, id string) error {
+	return editPRD(path, func(doc *PRDDocument) error {
+		story := doc.Story(id)
+		if story == nil {
+			return fmt.Errorf("story %s no longer exists", id)
+		}
+		var passes bool
+		if err := story.Get("passes", &passes); err != nil {
+			return err
+		}
+		return story.Set("passes", !passes)
+	})
+}
+
+// MoveStory swaps a story's priority with its neighbour's in priority order;
+// delta is -1 to move it up and 1 to move it down. Stories sharing a
+// priority are numbered apart first so the move is visible.
+func MoveStory(path, id string, delta int) error {
+	return editPRD(path, func(doc *PRDDocument) error {
+		type entry struct {
+			obj      *jsonObject
+			id       string
+			priority int
+		}
+		entries := make([]entry, len(doc.Stories))
+		for i, story := range doc.Stories {
+			entries[i].obj = story
+			story.Get("id", &entries[i].id)
+			story.Get("priority", &entries[i].priority)
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].priority < entries[j].priority })
+
+		idx := -1
+		for i, e := range entries {
+			if e.id == id {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("story %s no longer exists", id)
+		}
+		other := idx + delta
+		if other < 0 || other >= len(entries) {
+			return nil
+		}
+
+		if entries[idx].priority == entries[other].priority {
+			for i := range entries {
+				entries[i].priority = i + 1
+				if err := entries[i].obj.Set("priority", i+1); err != nil {
+					return err
+				}
+			}
+		}
+		a, b := entries[idx], entries[other]
+		if err := a.obj.Set("priority", b.priority); err != nil {
+			return err
+		}
+		return b.obj.Set("priority", a.priority)
+	})
+}
+
+var storyIDNumber = regexp.MustCompile(`^(.*?)(\d+)$`)
+
+// nextStoryID suggests an ID for a new story following the existing
+// numbering, e.g. US-007 after US-006
+func nextStoryID(stories []Story) string {
+	prefix, width, highest := "US-", 3, 0
+	for _, s := range stories {
+		m := storyIDNumber.FindStringSubmatch(s.ID)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		if n >= highest {
+			prefix, width, highest = m[1], len(m[2]), n
+		}
+	}
+	return fmt.Sprintf("%s%0*d", prefix, width, highest+1)
+}
+
+// nextPriority is one past the lowest priority in use
+func nextPriority(stories []Story) int {
+	highest := 0
+	for _, s := range stories {
+		highest = max(highest, s.Priority)
+	}
+	return highest + 1
+}
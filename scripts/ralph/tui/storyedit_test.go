@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPRDWithExtras has fields the PRD structs do not know about, in a key
+// order of its own
+const testPRDWithExtras = `{
+  "userStories": [
+    {
+      "estimate": 3,
+      "id": "US-001",
+      "title": "First",
+      "priority": 1,
+      "passes": false,
+      "labels": ["ui"]
+    },
+    {
+      "id": "US-002",
+      "title": "Second",
+      "priority": 2,
+      "passes": false,
+      "dependsOn": ["US-001"],
+      "estimate": 5
+    },
+    {
+      "id": "US-003",
+      "title": "Third",
+      "priority": 3,
+      "passes": false
+    }
+  ],
+  "project": "test",
+  "owner": {"team": "core"}
+}
+`
+
+func TestStoryEditsKeepUnknownFields(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		edit  func(path string) error
+		check func(t *testing.T, prd PRD)
+	}{
+		{"save", func(path string) error {
+			return SaveStory(path, "US-001", StoryEdit{ID: "US-010", Title: "Renamed", Priority: 1, AcceptanceCriteria: []string{"works"}})
+		}, func(t *testing.T, prd PRD) {
+			if s := GetStoryByID(prd.UserStories, "US-010"); s == nil || s.Title != "Renamed" {
+				t.Errorf("story was not saved: %+v", prd.UserStories)
+			}
+			if s := GetStoryByID(prd.UserStories, "US-002"); s == nil || len(s.DependsOn) != 1 || s.DependsOn[0] != "US-010" {
+				t.Errorf("dependency was not renamed: %+v", s)
+			}
+		}},
+		{"move", func(path string) error { return MoveStory(path, "US-002", -1) }, func(t *testing.T, prd PRD) {
+			if s := GetStoryByID(prd.UserStories, "US-002"); s == nil || s.Priority != 1 {
+				t.Errorf("story was not moved up: %+v", s)
+			}
+		}},
+		{"toggle", func(path string) error { return ToggleStoryPasses(path, "US-001") }, func(t *testing.T, prd PRD) {
+			if s := GetStoryByID(prd.UserStories, "US-001"); s == nil || !s.Passes {
+				t.Errorf("story does not pass: %+v", s)
+			}
+		}},
+		{"delete", func(path string) error { return DeleteStory(path, "US-003") }, func(t *testing.T, prd PRD) {
+			if len(prd.UserStories) != 2 || GetStoryByID(prd.UserStories, "US-003") != nil {
+				t.Errorf("story was not deleted: %+v", prd.UserStories)
+			}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "prd.json")
+			if err := os.WriteFile(path, []byte(testPRDWithExtras), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.edit(path); err != nil {
+				t.Fatal(err)
+			}
+
+			prd, err := LoadPRD(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, prd)
+
+			data, _ := os.ReadFile(path)
+			text := string(data)
+			for _, want := range []string{`"estimate": 3`, `"estimate": 5`, `"labels": [`, `"owner": {`, `"team": "core"`} {
+				if !strings.Contains(text, want) {
+					t.Errorf("edit dropped %s:\n%s", want, text)
+				}
+			}
+			if strings.Index(text, `"userStories"`) > strings.Index(text, `"project"`) {
+				t.Errorf("edit reordered the keys:\n%s", text)
+			}
+			if !strings.HasPrefix(text, "{\n  \"userStories\"") || !strings.HasSuffix(text, "}\n") {
+				t.Errorf("edit changed the indentation or final newline:\n%s", text)
+			}
+		})
+	}
+}
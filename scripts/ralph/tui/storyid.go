@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// storyClaimPhrases are the ways an agent names the story it works on; %s
+// stands for the PRD's story IDs
+var storyClaimPhrases = []string{
+	`(?i:working on):?\s+\[?(%s)\b`,
+	`(?i:next story):?\s+\[?(%s)\b`,
+	`feat(?:\([^)]*\))?:\s+\[?(%s)\b`,
+	`\[(%s)\]`,
+}
+
+// storyMatcher finds the story an agent says it is working on. Its patterns
+// are built from the IDs in the PRD, so whatever scheme the PRD uses is
+// recognised, after any story_patterns from the config.
+type storyMatcher struct {
+	patterns []*regexp.Regexp
+}
+
+// CompileStoryPatterns compiles the story_patterns from the config. Each
+// needs a group capturing the story ID: a group named "id", or else the
+// first one.
+func CompileStoryPatterns(exprs []string) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("story pattern %q: %w", expr, err)
+		}
+		if re.NumSubexp() == 0 {
+			return nil, fmt.Errorf("story pattern %q has no (group) capturing the story ID", expr)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+func newStoryMatcher(stories []Story, custom []*regexp.Regexp) storyMatcher {
+	sm := storyMatcher{patterns: append([]*regexp.Regexp(nil), custom...)}
+	if len(stories) == 0 {
+		return sm
+	}
+
+	// longest first, so US-0010 is not read as US-001
+	ids := make([]string, len(stories))
+	for i, s := range stories {
+		ids[i] = regexp.QuoteMeta(s.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return len(ids[i]) > len(ids[j]) })
+	alternatives := strings.Join(ids, "|")
+
+	for _, phrase := range storyClaimPhrases {
+		sm.patterns = append(sm.patterns, regexp.MustCompile(fmt.Sprintf(phrase, alternatives)))
+	}
+	return sm
+}
+
+// Match returns the story ID text names, if any
+func (sm storyMatcher) Match(text string) (string, bool) {
+	text = ansiEscape.ReplaceAllString(text, "")
+	for _, re := range sm.patterns {
+		m := re.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		group := 1
+		if i := re.SubexpIndex("id"); i > 0 {
+			group = i
+		}
+		if m[group] != "" {
+			return m[group], true
+		}
+	}
+	return "", false
+}
+
+// noteClaim records the story the agent on worker says it works on,
+// reporting when that is not the story it was given
+func (m *Model) noteClaim(workerID int, claimed string) {
+	iteration, scheduled, current := m.currentIteration, m.currentStoryID, &m.claimedStoryID
+	if w := m.worker(workerID); w != nil {
+		iteration, scheduled, current = w.Iteration, w.StoryID, &w.ClaimedStoryID
+	}
+	if claimed == *current {
+		return
+	}
+	*current = claimed
+
+	if scheduled != "" && claimed != scheduled {
+		m.report(Event{
+			Type:      EventStoryMismatch,
+			Iteration: iteration,
+			StoryID:   scheduled,
+			Worker:    workerID,
+			Message:   fmt.Sprintf("agent says it is working on %s, not %s", claimed, scheduled),
+		})
+	}
+}
+
+// renderClaim shows the story the agent claims next to the one scheduled,
+// flagged when they differ
+func renderClaim(scheduled, claimed string) string {
+	switch claimed {
+	case "":
+		return ""
+	case scheduled:
+		return HelpStyle.Render("agent on " + claimed)
+	default:
+		return StoryRejectedStyle.Render("⚠ agent on " + claimed + ", not " + scheduled)
+	}
+}
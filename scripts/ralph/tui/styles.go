@@ -52,9 +52,23 @@ var (
 				Foreground(Yellow).
 				Bold(true)
 
+	StorySelectedStyle = lipgloss.NewStyle().
+				Reverse(true).
+				Bold(true)
+
+	StoryVerifiedStyle = lipgloss.NewStyle().
+				Foreground(Green)
+
+	StoryRejectedStyle = lipgloss.NewStyle().
+				Foreground(Red)
+
 	StoryPendingStyle = lipgloss.NewStyle().
 				Foreground(LightGray)
 
+	StoryBlockedStyle = lipgloss.NewStyle().
+				Foreground(Gray).
+				Italic(true)
+
 	StatusBarStyle = lipgloss.NewStyle().
 			Foreground(LightGray).
 			Background(BgPanel).
@@ -72,8 +86,13 @@ var (
 	LogTextStyle = lipgloss.NewStyle().
 			Foreground(LightGray)
 
+	SearchMatchStyle = lipgloss.NewStyle().
+				Foreground(BgDark).
+				Background(Yellow)
+
 	SuccessIcon = lipgloss.NewStyle().Foreground(Green).Render("✓")
 	CurrentIcon = lipgloss.NewStyle().Foreground(Yellow).Render("▸")
 	PendingIcon = lipgloss.NewStyle().Foreground(DarkGray).Render(" ")
 	ErrorIcon   = lipgloss.NewStyle().Foreground(Red).Render("✗")
+	BlockedIcon = lipgloss.NewStyle().Foreground(Gray).Render("⊘")
 )
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -17,14 +20,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "?", "esc":
 				m.showHelp = false
 			case "q", "ctrl+c":
-				if m.runningCmd != nil && m.runningCmd.Process != nil {
-					m.runningCmd.Process.Kill()
-				}
-				return m, tea.Quit
+				return m, m.requestQuit()
 			}
 			return m, nil
 		}
 
+		switch m.overlay {
+		case OverlayArchiveConfirm:
+			return m.updateArchiveConfirm(msg)
+		case OverlayArchives:
+			return m.updateArchiveBrowser(msg)
+		case OverlayLogs:
+			return m.updateLogBrowser(msg)
+		case OverlayDiff:
+			return m.updateDiffView(msg)
+		case OverlayEditor:
+			return m.updateEditor(msg)
+		case OverlayDeleteConfirm:
+			return m.updateDeleteConfirm(msg)
+		}
+
 		if m.searchMode {
 			switch msg.String() {
 			case "esc":
@@ -41,6 +56,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.searchQuery += msg.String()
 				}
 			}
+			m.moveStoryCursor(0)
+			return m, nil
+		}
+
+		if m.focusedPanel == PanelLearnings && m.updateLearnings(msg) {
 			return m, nil
 		}
 
@@ -49,163 +69,679 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.showHelp = true
 
 		case "q", "ctrl+c":
-			if m.runningCmd != nil && m.runningCmd.Process != nil {
-				m.runningCmd.Process.Kill()
-			}
-			return m, tea.Quit
+			return m, m.requestQuit()
 
 		case "/":
 			m.searchMode = true
 			m.searchQuery = ""
 
 		case "tab":
-			if m.focusedPanel == PanelStories {
-				m.focusedPanel = PanelOutput
-			} else {
-				m.focusedPanel = PanelStories
-			}
+			m.cycleFocus()
 
 		case "up", "k":
 			if m.focusedPanel == PanelStories {
-				if m.storyScroll > 0 {
-					m.storyScroll--
-				}
+				m.moveStoryCursor(-1)
 			} else {
-				m.outputViewport.LineUp(1)
+				m.focusedViewport().LineUp(1)
 			}
 
 		case "down", "j":
 			if m.focusedPanel == PanelStories {
-				maxScroll := len(m.stories) - 10
-				if maxScroll < 0 {
-					maxScroll = 0
-				}
-				if m.storyScroll < maxScroll {
-					m.storyScroll++
-				}
+				m.moveStoryCursor(1)
 			} else {
-				m.outputViewport.LineDown(1)
+				m.focusedViewport().LineDown(1)
 			}
 
 		case "g":
-			if m.focusedPanel == PanelOutput {
-				m.outputViewport.GotoTop()
+			if m.focusedPanel != PanelStories {
+				m.focusedViewport().GotoTop()
 			} else {
-				m.storyScroll = 0
+				m.moveStoryCursor(-len(m.stories))
 			}
 
 		case "G":
-			if m.focusedPanel == PanelOutput {
-				m.outputViewport.GotoBottom()
+			if m.focusedPanel != PanelStories {
+				m.focusedViewport().GotoBottom()
 			} else {
-				maxScroll := len(m.stories) - 10
-				if maxScroll < 0 {
-					maxScroll = 0
+				m.moveStoryCursor(len(m.stories))
+			}
+
+		case "enter":
+			if m.focusedPanel == PanelStories {
+				return m, m.pickStory()
+			}
+
+		case "e", "n", "D", "K", "J", " ":
+			if m.focusedPanel == PanelStories {
+				return m, m.editSelectedStory(msg.String())
+			}
+
+		case "u":
+			if story := m.selectedStory(); story != nil && m.focusedPanel == PanelStories {
+				m.unstick(*story)
+			}
+
+		case "t":
+			selected := m.selectedStory()
+			m.treeView = !m.treeView
+			if selected != nil {
+				for i, s := range m.filterStories() {
+					if s.ID == selected.ID {
+						m.storyCursor = i
+					}
 				}
-				m.storyScroll = maxScroll
 			}
+			m.moveStoryCursor(0)
 
 		case "r":
 			if !m.processRunning && !m.processDone {
+				// starting by hand is the user's go-ahead after an error
+				m.agentFailed = false
+				return m, m.startIteration()
+			}
+
+		case "p":
+			m.paused = !m.paused
+			if m.paused {
+				m.notify("⏸ Loop paused after the current iteration")
+				break
+			}
+			m.agentFailed = false
+			if m.parallel() && m.currentIteration > 0 {
+				return m, m.fillWorkers()
+			} else if m.currentIteration > 0 && m.canContinue() {
 				return m, m.startIteration()
 			}
+
+		case "z":
+			if len(m.activeProcs()) > 0 && !m.quitting {
+				m.toggleSuspend()
+			}
+
+		case "Q":
+			if !m.agentRunning() {
+				return m, m.quitCmd()
+			}
+			m.stopAfterCurrent = !m.stopAfterCurrent
+			if m.stopAfterCurrent {
+				m.notify("Exiting after the current iteration")
+			}
+
+		case "A":
+			if !m.processRunning && m.initError == nil {
+				branch := m.prd.BranchName
+				m.pendingArchive = &BranchChange{Previous: branch, Current: branch}
+				m.overlay = OverlayArchiveConfirm
+			}
+
+		case "B":
+			m.overlay = OverlayArchives
+			m.archiveCursor = 0
+			return m, loadArchivesCmd(m.prdPath)
+
+		case "L":
+			return m, m.openLogBrowser()
+
+		case "l":
+			m.toggleLearnings()
+
+		case "c":
+			m.toggleToolBlocks()
+
+		case "d":
+			story := m.selectedStory()
+			if m.focusedPanel != PanelStories || story == nil {
+				story = GetStoryByID(m.stories, m.currentStoryID)
+			}
+			if story != nil {
+				return m, m.openDiff(story)
+			}
 		}
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 
-		outputHeight := m.height - 10
-		if outputHeight < 5 {
-			outputHeight = 5
+		// the same size the Output panel renders at, so following the
+		// bottom shows the latest line
+		leftWidth := m.width/2 - panelHorizontalPad
+		panelHeight := max(minPanelHeight, m.height-totalUIOverhead)
+		m.outputViewport.Width = max(20, m.width-leftWidth-panelGap-4)
+		m.outputViewport.Height = max(1, panelHeight-3)
+		m.resizeArchiveViewport()
+		m.resizeLogViewport()
+		m.resizeDiffViewport()
+		m.resizeWorkerViewports()
+		m.resizeLearningsViewport()
+		if m.form != nil {
+			m.form.resize(m.width, m.height)
 		}
-		outputWidth := m.width/2 - 4
-		if outputWidth < 20 {
-			outputWidth = 20
-		}
-
-		m.outputViewport.Width = outputWidth
-		m.outputViewport.Height = outputHeight
 
 	case PRDUpdatedMsg:
-		if msg.Err == nil {
-			oldCompleted := m.completedCount
-			m.prd = msg.PRD
-			m.stories = msg.PRD.UserStories
-			m.completedCount = CountCompleted(m.stories)
-
-			if m.currentStoryID != "" && m.completedCount > oldCompleted {
-				story := GetStoryByID(m.stories, m.currentStoryID)
-				if story != nil && story.Passes {
-					if startTime, exists := m.storyStartTimes[m.currentStoryID]; exists {
-						duration := time.Since(startTime)
-						m.storyDurations[m.currentStoryID] = duration
-					}
-				}
+		if msg.Err != nil {
+			if m.prdError == nil {
+				m.report(Event{Type: EventError, Message: msg.Err.Error()})
 			}
+			m.prdError = msg.Err
+		} else {
+			m.prdError = nil
+			m.applyPRD(msg.PRD)
+			m.notify("✓ PRD updated")
+		}
+		cmds = append(cmds, watchPRDCmd(m.prdWatcher))
 
-			if m.completedCount == len(m.stories) {
-				m.processDone = true
+	case ProgressUpdatedMsg:
+		if msg.Err == nil {
+			m.progress = msg.Progress
+			if m.learningsEntry >= len(m.progress.Entries) {
+				m.learningsEntry = -1
 			}
-
-			m.prdUpdateNotif = "✓ PRD updated"
-			m.prdUpdateNotifEnd = time.Now().Add(3 * time.Second)
+			m.refreshLearnings()
 		}
-		cmds = append(cmds, watchPRDCmd(m.prdPath))
+		cmds = append(cmds, watchProgressCmd(m.progressWatcher))
 
 	case OutputLineMsg:
-		formattedLine := formatTimestamp(msg.Timestamp) + " " + msg.Line
-		m.outputLines = append(m.outputLines, formattedLine)
-		m.outputViewport.SetContent(strings.Join(m.outputLines, "\n"))
-		m.outputViewport.GotoBottom()
+		if msg.Worker > 0 {
+			m.workerOutput(msg)
+			cmds = append(cmds, listenForOutputCmd(m.msgChan))
+			break
+		}
+		m.lastOutputAt = msg.Timestamp
+		if msg.Line != "" {
+			m.appendOutputLine(eventLine(msg))
+			m.report(outputEvent(msg, m.currentIteration, m.currentStoryID))
+		}
 
-		if storyID, found := parseStoryFromLine(msg.Line); found {
-			m.currentStoryID = storyID
+		if claimed, found := m.storyMatcher.Match(msg.Line); found {
+			m.noteClaim(0, claimed)
+		}
+		if u := msg.Event.Usage; u != nil {
+			m.noteUsage(0, *u)
 		}
 
-		if checkCompleteSignal(msg.Line) {
-			m.processDone = true
+		// the PRD decides whether the run is done; the signal is only
+		// checked against it once the agent exits
+		if msg.Event.Complete {
+			m.completeSignalled = true
 		}
 
 		cmds = append(cmds, listenForOutputCmd(m.msgChan))
 
 	case ProcessStartedMsg:
+		if msg.Worker > 0 {
+			m.workerStarted(msg)
+			cmds = append(cmds, listenForOutputCmd(m.msgChan))
+			break
+		}
 		m.currentIteration = msg.Iteration
 		m.currentStoryID = msg.StoryID
+		m.claimedStoryID = ""
 		m.iterationStart = time.Now()
 		m.storyStartTimes[msg.StoryID] = time.Now()
 		m.processRunning = true
-		m.runningCmd = msg.Cmd
+		m.runningProc = msg.Proc
+		m.lastOutputAt = time.Now()
+		m.killReason = ""
+		m.suspendedFor = 0
 		cmds = append(cmds, listenForOutputCmd(m.msgChan))
 
 	case ProcessExitedMsg:
+		if msg.Worker > 0 {
+			return m.workerExited(msg)
+		}
 		m.processRunning = false
-		m.runningCmd = nil
+		m.runningProc = nil
+		cmds = append(cmds, listenForOutputCmd(m.msgChan))
+		m.awaitingChecks = make(map[string]bool)
+
+		saveRecord := m.recordIteration(msg, m.killReason, m.claimedStoryID, m.iterationUsage)
+		m.iterationUsage = TokenUsage{}
+		m.killReason = ""
+		cmds = append(cmds, saveRecord)
+
+		if m.completeSignalled && !m.processDone {
+			m.appendOutput(fmt.Sprintf("⚠ The agent signalled completion, but %d of %d stories do not pass",
+				len(m.stories)-m.completedCount, len(m.stories)))
+		}
+		m.completeSignalled = false
+
+		m.suspended = false
+		if m.quitting || m.stopAfterCurrent {
+			// the agent is gone; exit once its record is saved
+			return m, tea.Sequence(saveRecord, m.quitCmd())
+		}
+
+		switch {
+		case m.processDone:
+		case m.agentFailed, m.paused:
+		case m.canContinue():
+			cmds = append(cmds, m.startIteration())
+		}
+
+		if m.headless && !m.processRunning {
+			// let the history write land before the program exits
+			return m, tea.Sequence(saveRecord, m.finishHeadless())
+		}
 
-		if msg.Complete || m.processDone {
-			m.processDone = true
-		} else if m.currentIteration < m.maxIterations && m.completedCount < len(m.stories) {
+	case AutoStartMsg:
+		if m.headless {
+			m.report(Event{Type: EventRunStart, Message: m.runSummary()})
+			if m.processError != nil {
+				m.report(Event{Type: EventError, Message: m.processError.Error()})
+			}
+		}
+		if !m.processRunning && !m.processDone {
 			cmds = append(cmds, m.startIteration())
 		}
+		if m.headless && !m.processRunning {
+			return m, m.finishHeadless()
+		}
+
+	case InterruptMsg:
+		return m, m.requestQuit()
 
 	case TickMsg:
-		if !m.prdUpdateNotifEnd.IsZero() && time.Now().After(m.prdUpdateNotifEnd) {
-			m.prdUpdateNotif = ""
-			m.prdUpdateNotifEnd = time.Time{}
+		m.checkIterationDeadlines(time.Time(msg))
+		m.checkWorkerDeadlines(time.Time(msg))
+		if !m.statusNotifEnd.IsZero() && time.Now().After(m.statusNotifEnd) {
+			m.statusNotif = ""
+			m.statusNotifEnd = time.Time{}
 		}
 		cmds = append(cmds, tickCmd())
 
+	case RunArchivedMsg:
+		m.pendingArchive = nil
+		if msg.Err != nil {
+			m.processError = msg.Err
+			m.report(Event{Type: EventError, Message: "archive failed: " + msg.Err.Error()})
+		} else {
+			m.storyStartTimes = make(map[string]time.Time)
+			m.storyDurations = make(map[string]time.Duration)
+			m.history = nil
+			m.storyAttempts = make(map[string]int)
+			m.attemptsReset = make(map[string]int)
+			m.notify("✓ Archived to " + msg.Folder)
+			m.report(Event{Type: EventArchived, Message: msg.Folder})
+		}
+		if m.autoStart && m.currentIteration == 0 {
+			cmds = append(cmds, func() tea.Msg { return AutoStartMsg{} })
+		}
+
+	case ArchivesLoadedMsg:
+		if msg.Err != nil {
+			m.processError = msg.Err
+			m.overlay = OverlayNone
+		} else {
+			m.archives = msg.Runs
+			m.showArchive(0)
+		}
+
+	case LogLoadedMsg:
+		if m.logPath(m.logRecord(m.logCursor)) != msg.Path {
+			break
+		}
+		if msg.Err != nil {
+			m.logLines = nil
+			m.logViewport.SetContent(lipgloss.NewStyle().Foreground(Red).Render(msg.Err.Error()))
+			break
+		}
+		m.logLines = msg.Lines
+		m.findLogMatches()
+		if len(m.logMatches) == 0 {
+			m.logViewport.GotoTop()
+		}
+
+	case DiffLoadedMsg:
+		m.showDiff(msg)
+
+	case StoryEditedMsg:
+		m.storyEdited(msg)
+
 	case ErrorMsg:
 		m.processError = msg.Err
+		m.report(Event{Type: EventError, Message: msg.Err.Error()})
+
+	default:
+		// cursor blinks for the editor's inputs
+		if m.form != nil {
+			cmds = append(cmds, m.form.update(msg))
+		}
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
+// moveStoryCursor moves the Stories panel selection, keeping it in view
+func (m *Model) moveStoryCursor(delta int) {
+	count := len(m.filterStories())
+	m.storyCursor = max(0, min(m.storyCursor+delta, count-1))
+
+	visible := m.storiesVisibleCount()
+	if m.storyCursor < m.storyScroll {
+		m.storyScroll = m.storyCursor
+	} else if m.storyCursor >= m.storyScroll+visible {
+		m.storyScroll = m.storyCursor - visible + 1
+	}
+	m.storyScroll = max(0, min(m.storyScroll, count-visible))
+
+	if m.showLearnings && m.focusedPanel == PanelStories {
+		m.followSelectedStory()
+	}
+}
+
+// selectedStory returns the story under the Stories panel cursor
+func (m Model) selectedStory() *Story {
+	stories := m.filterStories()
+	if m.storyCursor < 0 || m.storyCursor >= len(stories) {
+		return nil
+	}
+	return &stories[m.storyCursor]
+}
+
+// pickStory targets the next iteration at the selected story, starting it
+// straight away when the agent is idle. While an iteration runs, picking
+// the same story again cancels the choice.
+func (m *Model) pickStory() tea.Cmd {
+	story := m.selectedStory()
+	if story == nil {
+		return nil
+	}
+
+	if unmet := UnmetDependencies(m.stories, *story); len(unmet) > 0 && !story.Passes {
+		m.notify("⚠ " + story.ID + " waits on " + strings.Join(unmet, ", ") + "; picking it anyway")
+	}
+
+	if !m.processRunning && !m.quitting {
+		m.pickedStoryID = story.ID
+		m.agentFailed = false
+		return m.startIteration()
+	}
+
+	if m.pickedStoryID == story.ID {
+		m.pickedStoryID = ""
+		m.notify("Next iteration back to the highest priority story")
+	} else {
+		m.pickedStoryID = story.ID
+		m.notify("Next iteration will work on " + story.ID)
+	}
+	return nil
+}
+
+// previousFailureLog returns the log of the story's latest attempt if that
+// attempt did not make it pass
+func (m Model) previousFailureLog(storyID string) string {
+	for i := len(m.history) - 1; i >= 0; i-- {
+		rec := m.history[i]
+		if rec.StoryID != storyID {
+			continue
+		}
+		if rec.Passed {
+			return ""
+		}
+		return rec.LogPath
+	}
+	return ""
+}
+
+// canContinue reports whether the loop has iterations and stories left
+func (m Model) canContinue() bool {
+	return !m.processRunning && !m.processDone &&
+		m.currentIteration < m.maxIterations && m.completedCount < len(m.stories) &&
+		(m.nextStory() != nil || m.pickedStoryID != "")
+}
+
+// toggleSuspend freezes or thaws the running agents. Time spent suspended
+// does not count towards the iteration timeout.
+func (m *Model) toggleSuspend() {
+	if m.suspended {
+		for _, proc := range m.activeProcs() {
+			if err := proc.Resume(); err != nil {
+				m.processError = err
+			}
+		}
+		m.suspended = false
+		suspended := time.Since(m.suspendedAt)
+		m.suspendedFor += suspended
+		m.lastOutputAt = time.Now()
+		for i := range m.workers {
+			if m.workers[i].Proc != nil {
+				m.workers[i].SuspendedFor += suspended
+				m.workers[i].LastOutputAt = m.lastOutputAt
+			}
+		}
+		m.announce("▶ Agent resumed")
+		return
+	}
+
+	for _, proc := range m.activeProcs() {
+		if err := proc.Suspend(); err != nil {
+			m.processError = err
+			return
+		}
+	}
+	m.suspended = true
+	m.suspendedAt = time.Now()
+	m.announce("⏸ Agent suspended (z to resume)")
+}
+
+// recordIteration applies the outcome of a finished iteration — verdict,
+// reloaded PRD, history record and events — and returns the command that
+// saves the record
+func (m *Model) recordIteration(msg ProcessExitedMsg, killReason, claimed string, usage TokenUsage) tea.Cmd {
+	if v := msg.Verification; v != nil {
+		for _, id := range v.Stories {
+			m.verdicts[id] = *v
+		}
+		m.report(Event{Type: EventVerification, Iteration: msg.Iteration, StoryID: msg.StoryID, Worker: msg.Worker, Message: v.Summary()})
+	}
+	if msg.PRD != nil {
+		m.applyPRD(*msg.PRD)
+	}
+
+	rec := IterationRecord{
+		Session:   m.session,
+		Branch:    m.prd.BranchName,
+		Iteration: msg.Iteration,
+		StoryID:   msg.StoryID,
+		Start:     msg.Start,
+		End:       msg.End,
+		ExitCode:  msg.ExitCode,
+		CommitSHA: msg.CommitSHA,
+		Commits:   msg.Commits,
+
+		RolledBack: msg.RolledBack,
+		PatchPath:  msg.PatchPath,
+		LogPath:    msg.LogPath,
+		Worker:     msg.Worker,
+		MergeError: msg.MergeErr,
+
+		Verification: msg.Verification,
+	}
+	if m.runner != nil {
+		rec.Agent = m.runner.Name()
+	}
+	if claimed != msg.StoryID {
+		rec.ClaimedStoryID = claimed
+	}
+	if !usage.IsZero() {
+		rec.Usage = &usage
+	}
+	if story := GetStoryByID(m.stories, msg.StoryID); story != nil {
+		rec.Passed = story.Passes
+	}
+	if msg.Err != nil {
+		rec.Error = msg.Err.Error()
+	}
+	if killReason != "" {
+		rec.TimedOut = true
+		rec.Error = killReason
+	}
+	for _, c := range msg.Commits {
+		m.appendOutputTo(msg.Worker, "[git] "+c.Short()+" "+c.Subject+" ("+c.StoryID+")")
+	}
+	m.history = append(m.history, rec)
+	m.storyAttempts = attemptsFromHistory(m.history)
+	m.noteAttempt(msg.Worker, msg.StoryID)
+
+	exitCode := msg.ExitCode
+	m.report(Event{
+		Type:      EventIterationEnd,
+		Iteration: msg.Iteration,
+		StoryID:   msg.StoryID,
+		Worker:    msg.Worker,
+		ExitCode:  &exitCode,
+		Duration:  formatDuration(msg.End.Sub(msg.Start)),
+		Usage:     rec.Usage,
+	})
+
+	if msg.Err != nil {
+		m.processError = msg.Err
+		m.agentFailed = true
+		m.report(Event{Type: EventError, Iteration: msg.Iteration, Worker: msg.Worker, Message: msg.Err.Error()})
+	}
+
+	return appendHistoryCmd(historyPath(m.ralphDir), rec)
+}
+
+// requestQuit stops the running agent before exiting. The first request
+// sends SIGTERM to the agent's process group and waits for it to exit (see
+// ProcessExitedMsg); a second one kills the group and exits immediately.
+func (m *Model) requestQuit() tea.Cmd {
+	if !m.agentRunning() {
+		return m.quitCmd()
+	}
+	if m.quitting {
+		for _, proc := range m.activeProcs() {
+			proc.Kill()
+		}
+		return m.quitCmd()
+	}
+
+	if m.suspended {
+		// a stopped process cannot act on SIGTERM
+		m.toggleSuspend()
+	}
+	m.quitting = true
+	for _, proc := range m.activeProcs() {
+		proc.Terminate(m.killGrace)
+	}
+	m.announce("Stopping agent (SIGTERM, SIGKILL after " + formatDuration(m.killGrace) + ")…")
+	return nil
+}
+
+func (m *Model) quitCmd() tea.Cmd {
+	if m.headless {
+		return m.finishRun(ExitInterrupted, "interrupted")
+	}
+	return tea.Quit
+}
+
+// checkIterationDeadlines kills the running agent once it exceeds the
+// iteration timeout or has been silent for longer than the stall timeout
+func (m *Model) checkIterationDeadlines(now time.Time) {
+	if m.runningProc == nil || m.killReason != "" {
+		return
+	}
+
+	switch {
+	case m.quitting, m.suspended:
+		return
+	case m.iterationTimeout > 0 && now.Sub(m.iterationStart)-m.suspendedFor > m.iterationTimeout:
+		m.killReason = "timed out after " + formatDuration(m.iterationTimeout)
+	case m.stallTimeout > 0 && now.Sub(m.lastOutputAt) > m.stallTimeout:
+		m.killReason = "stalled: no output for " + formatDuration(m.stallTimeout)
+	default:
+		return
+	}
+
+	m.runningProc.Terminate(m.killGrace)
+	m.appendOutput("⚠ Iteration " + strconv.Itoa(m.currentIteration) + " " + m.killReason + ", stopping agent")
+	m.report(Event{Type: EventTimeout, Iteration: m.currentIteration, StoryID: m.currentStoryID, Message: m.killReason})
+}
+
+// appendOutputTo adds a line to a worker's pane, or the Output panel for 0
+func (m *Model) appendOutputTo(worker int, line string) {
+	if w := m.worker(worker); w != nil {
+		w.appendLine(time.Now(), line)
+		return
+	}
+	m.appendOutput(line)
+}
+
+// notify flashes a message in the status bar
+func (m *Model) notify(text string) {
+	m.statusNotif = text
+	m.statusNotifEnd = time.Now().Add(3 * time.Second)
+}
+
+// applyPRD replaces the loaded PRD and updates completion tracking
+func (m *Model) applyPRD(prd PRD) {
+	oldCompleted := m.completedCount
+	oldStories := m.stories
+
+	// parallel workers verify in their worktrees before merging
+	if m.processRunning && m.verifier != nil && !m.parallel() {
+		prd.UserStories = m.holdUnverified(prd.UserStories)
+	}
+	m.prd = prd
+	m.stories = prd.UserStories
+	m.completedCount = CountCompleted(m.stories)
+	m.storyMatcher = newStoryMatcher(m.stories, m.storyPatterns)
+
+	if m.currentStoryID != "" && m.completedCount > oldCompleted {
+		story := GetStoryByID(m.stories, m.currentStoryID)
+		if story != nil && story.Passes {
+			if startTime, exists := m.storyStartTimes[m.currentStoryID]; exists {
+				duration := time.Since(startTime)
+				m.storyDurations[m.currentStoryID] = duration
+			}
+		}
+	}
+
+	for _, story := range m.stories {
+		if old := GetStoryByID(oldStories, story.ID); story.Passes && (old == nil || !old.Passes) {
+			m.report(Event{Type: EventStoryPassed, Iteration: m.currentIteration, StoryID: story.ID})
+		}
+	}
+
+	if m.completedCount == len(m.stories) {
+		m.processDone = true
+	}
+}
+
+// holdUnverified keeps stories the agent flipped to passing mid-iteration
+// pending until the checks have run
+func (m *Model) holdUnverified(stories []Story) []Story {
+	held := make([]Story, len(stories))
+	copy(held, stories)
+	for i, story := range held {
+		if old := GetStoryByID(m.stories, story.ID); story.Passes && (old == nil || !old.Passes) {
+			held[i].Passes = false
+			m.awaitingChecks[story.ID] = true
+		} else if !story.Passes {
+			delete(m.awaitingChecks, story.ID)
+		}
+	}
+	return held
+}
+
 func (m *Model) startIteration() tea.Cmd {
+	if m.parallel() {
+		return m.fillWorkers()
+	}
+	nextStory := m.nextStory()
+	pinned := false
+	if picked := GetStoryByID(m.stories, m.pickedStoryID); picked != nil {
+		nextStory = picked
+		pinned = true
+	}
+	m.pickedStoryID = ""
+	if nextStory == nil && len(m.stuckStories()) > 0 {
+		m.notify("No story left to try — " + m.stuckSummary() + " (u to retry)")
+		return nil
+	}
 	m.currentIteration++
 
-	nextStory := GetNextStory(m.stories)
 	storyID := ""
 	if nextStory != nil {
 		storyID = nextStory.ID
@@ -215,15 +751,27 @@ func (m *Model) startIteration() tea.Cmd {
 	m.iterationStart = time.Now()
 	m.processRunning = true
 
-	m.outputLines = append(m.outputLines, "")
-	m.outputLines = append(m.outputLines, formatTimestamp(time.Now())+" "+strings.Repeat("═", 40))
-	m.outputLines = append(m.outputLines, formatTimestamp(time.Now())+" Starting iteration "+string(rune('0'+m.currentIteration))+" - "+storyID)
-	m.outputLines = append(m.outputLines, formatTimestamp(time.Now())+" "+strings.Repeat("═", 40))
-	m.outputViewport.SetContent(strings.Join(m.outputLines, "\n"))
-	m.outputViewport.GotoBottom()
-
-	return tea.Batch(
-		runIterationCmd(m.promptPath, m.projectRoot, m.currentIteration, storyID, m.msgChan),
-		listenForOutputCmd(m.msgChan),
-	)
+	m.outputLines = append(m.outputLines, outputLine{})
+	m.appendOutput(strings.Repeat("═", 40))
+	m.appendOutput("Starting iteration " + strconv.Itoa(m.currentIteration) + " - " + storyID)
+	m.appendOutput(strings.Repeat("═", 40))
+
+	m.report(Event{Type: EventIterationStart, Iteration: m.currentIteration, StoryID: storyID})
+
+	return runIterationCmd(iterationSpec{
+		Iteration:       m.currentIteration,
+		MaxIterations:   m.maxIterations,
+		StoryID:         storyID,
+		Pinned:          pinned,
+		Runner:          m.runner,
+		PRDPath:         m.prdPath,
+		PromptPath:      m.promptPath,
+		ProgressPath:    m.progressPath,
+		ProjectRoot:     m.projectRoot,
+		RalphDir:        m.ralphDir,
+		PreviousLogPath: m.previousFailureLog(storyID),
+		Verifier:        m.verifier,
+		Branch:          m.prd.BranchName,
+		Rollback:        m.rollback,
+	}, m.msgChan)
 }
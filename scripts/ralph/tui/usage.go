@@ -0,0 +1,151 @@
+package main
+
+import "fmt"
+
+// TokenUsage is what the agent reported spending, for one model step or
+// summed over iterations
+type TokenUsage struct {
+	Input      int     `json:"input"`
+	Output     int     `json:"output"`
+	Reasoning  int     `json:"reasoning,omitempty"`
+	CacheRead  int     `json:"cacheRead,omitempty"`
+	CacheWrite int     `json:"cacheWrite,omitempty"`
+	Cost       float64 `json:"cost,omitempty"`
+}
+
+// Add accumulates o into u
+func (u *TokenUsage) Add(o TokenUsage) {
+	u.Input += o.Input
+	u.Output += o.Output
+	u.Reasoning += o.Reasoning
+	u.CacheRead += o.CacheRead
+	u.CacheWrite += o.CacheWrite
+	u.Cost += o.Cost
+}
+
+// Tokens counts every token, cached or not
+func (u TokenUsage) Tokens() int {
+	return u.Input + u.Output + u.Reasoning + u.CacheRead + u.CacheWrite
+}
+
+func (u TokenUsage) IsZero() bool {
+	return u == TokenUsage{}
+}
+
+// String shows the cost, when the agent reports one, and the tokens, e.g.
+// "$0.42 · 1.2M tok"
+func (u TokenUsage) String() string {
+	if u.Cost == 0 {
+		return formatTokens(u.Tokens()) + " tok"
+	}
+	return formatCost(u.Cost) + " · " + formatTokens(u.Tokens()) + " tok"
+}
+
+func formatCost(cost float64) string {
+	if cost < 0.01 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+func formatTokens(n int) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%d", n)
+	case n < 1_000_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	}
+}
+
+// noteUsage adds a step's usage to the iteration running on worker
+func (m *Model) noteUsage(workerID int, u TokenUsage) {
+	if w := m.worker(workerID); w != nil {
+		w.Usage.Add(u)
+		return
+	}
+	m.iterationUsage.Add(u)
+}
+
+// liveUsage is the usage of the iterations still running, by story
+func (m Model) liveUsage() map[string]TokenUsage {
+	live := make(map[string]TokenUsage)
+	add := func(storyID string, u TokenUsage) {
+		sum := live[storyID]
+		sum.Add(u)
+		live[storyID] = sum
+	}
+	if !m.iterationUsage.IsZero() {
+		add(m.currentStoryID, m.iterationUsage)
+	}
+	for _, w := range m.workers {
+		if !w.Usage.IsZero() {
+			add(w.StoryID, w.Usage)
+		}
+	}
+	return live
+}
+
+// storyUsage is what each story has cost so far over all its iterations,
+// including those still running
+func (m Model) storyUsage() map[string]TokenUsage {
+	usage := storyUsageFromHistory(m.history)
+	for id, u := range m.liveUsage() {
+		sum := usage[id]
+		sum.Add(u)
+		usage[id] = sum
+	}
+	return usage
+}
+
+// runUsage is what the run has cost so far
+func (m Model) runUsage() TokenUsage {
+	var total TokenUsage
+	for _, u := range m.storyUsage() {
+		total.Add(u)
+	}
+	return total
+}
+
+// projectedUsage estimates what the pending stories will cost from what the
+// stories that passed cost on average, failed attempts included
+func (m Model) projectedUsage() (TokenUsage, bool) {
+	usage := m.storyUsage()
+	var spent TokenUsage
+	passed := 0
+	for _, s := range m.stories {
+		if u, ok := usage[s.ID]; ok && s.Passes {
+			spent.Add(u)
+			passed++
+		}
+	}
+	remaining := len(m.stories) - m.completedCount
+	if passed == 0 || remaining == 0 {
+		return TokenUsage{}, false
+	}
+
+	scale := float64(remaining) / float64(passed)
+	return TokenUsage{
+		Input:      int(float64(spent.Input) * scale),
+		Output:     int(float64(spent.Output) * scale),
+		Reasoning:  int(float64(spent.Reasoning) * scale),
+		CacheRead:  int(float64(spent.CacheRead) * scale),
+		CacheWrite: int(float64(spent.CacheWrite) * scale),
+		Cost:       spent.Cost * scale,
+	}, true
+}
+
+// renderUsageStats shows the run's spend and the projected cost of the rest
+// next to the time estimates
+func (m Model) renderUsageStats() string {
+	spent := m.runUsage()
+	if spent.IsZero() {
+		return ""
+	}
+	stats := " │ Spent: " + spent.String()
+	if rest, ok := m.projectedUsage(); ok {
+		stats += " │ To go: ~" + rest.String()
+	}
+	return stats
+}
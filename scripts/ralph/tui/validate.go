@@ -0,0 +1,262 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prdSchema is the JSON Schema ValidatePRD implements, for editors and CI
+//
+//go:embed prd.schema.json
+var prdSchema []byte
+
+// PRDProblem is one way a prd.json breaks the schema
+type PRDProblem struct {
+	// Path locates the value, e.g. userStories[2].title
+	Path    string
+	Message string
+}
+
+func (p PRDProblem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return p.Path + ": " + p.Message
+}
+
+// PRDValidationError lists everything wrong with a prd.json
+type PRDValidationError struct {
+	Problems []PRDProblem
+}
+
+func (e *PRDValidationError) Error() string {
+	msg := "invalid prd.json: " + e.Problems[0].String()
+	if n := len(e.Problems) - 1; n == 1 {
+		msg += " (and 1 more problem)"
+	} else if n > 1 {
+		msg += fmt.Sprintf(" (and %d more problems)", n)
+	}
+	return msg
+}
+
+// ValidatePRD checks prd.json data against prd.schema.json, plus what the
+// schema cannot express: unique story IDs and sound dependencies. Unknown
+// fields are allowed.
+func ValidatePRD(data []byte) []PRDProblem {
+	var problems []PRDProblem
+	report := func(path, format string, args ...any) {
+		problems = append(problems, PRDProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var root jsonObject
+	if err := json.Unmarshal(data, &root); err != nil {
+		report("", "not a JSON object: %v", err)
+		return problems
+	}
+
+	for _, key := range []string{"project", "branchName", "description", "agent"} {
+		checkField(&root, key, key, "string", false, report)
+	}
+	if !checkField(&root, "userStories", "userStories", "array", true, report) {
+		return problems
+	}
+
+	var stories []jsonObject
+	if err := root.Get("userStories", &stories); err != nil {
+		report("userStories", "every story must be an object")
+		return problems
+	}
+
+	seen := make(map[string]string)
+	for i, story := range stories {
+		path := fmt.Sprintf("userStories[%d]", i)
+		at := func(key string) string { return path + "." + key }
+
+		if checkField(&story, "id", at("id"), "string", true, report) {
+			var id string
+			story.Get("id", &id)
+			switch {
+			case strings.TrimSpace(id) == "":
+				report(at("id"), "must not be empty")
+			case seen[id] != "":
+				report(at("id"), "duplicate ID %q, also used by %s", id, seen[id])
+			default:
+				seen[id] = path
+			}
+		}
+		if checkField(&story, "title", at("title"), "string", true, report) {
+			var title string
+			story.Get("title", &title)
+			if strings.TrimSpace(title) == "" {
+				report(at("title"), "must not be empty")
+			}
+		}
+		checkField(&story, "description", at("description"), "string", false, report)
+		checkField(&story, "notes", at("notes"), "string", false, report)
+		checkField(&story, "passes", at("passes"), "boolean", true, report)
+		checkField(&story, "acceptanceCriteria", at("acceptanceCriteria"), "string array", false, report)
+		if checkField(&story, "dependsOn", at("dependsOn"), "string array", false, report) {
+			var deps []string
+			story.Get("dependsOn", &deps)
+			listed := make(map[string]bool)
+			for _, dep := range deps {
+				if listed[dep] {
+					report(at("dependsOn"), "lists %q more than once", dep)
+				}
+				listed[dep] = true
+			}
+		}
+
+		if checkField(&story, "priority", at("priority"), "integer", true, report) {
+			var priority int
+			story.Get("priority", &priority)
+			if priority < 0 {
+				report(at("priority"), "must not be negative, got %d", priority)
+			}
+		}
+		if checkField(&story, "maxAttempts", at("maxAttempts"), "integer", false, report) {
+			var attempts int
+			story.Get("maxAttempts", &attempts)
+			if attempts < 1 {
+				report(at("maxAttempts"), "must be at least 1, got %d", attempts)
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		var prd PRD
+		if err := json.Unmarshal(data, &prd); err != nil {
+			report("", "%v", err)
+		} else if err := ValidateDependencies(prd.UserStories); err != nil {
+			report("", "%v", err)
+		}
+	}
+	return problems
+}
+
+// checkField reports a value of the wrong type, or a missing required one,
+// and returns whether the field is present and well-typed
+func checkField(obj *jsonObject, key, path, kind string, required bool, report func(path, format string, args ...any)) bool {
+	raw, ok := obj.values[key]
+	if !ok {
+		if required {
+			report(path, "required %s is missing", kind)
+		}
+		return false
+	}
+
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		report(path, "%v", err)
+		return false
+	}
+
+	valid := false
+	switch kind {
+	case "string":
+		_, valid = value.(string)
+	case "boolean":
+		_, valid = value.(bool)
+	case "integer":
+		n, isNumber := value.(float64)
+		valid = isNumber && n == float64(int(n))
+	case "array":
+		_, valid = value.([]any)
+	case "string array":
+		items, isArray := value.([]any)
+		valid = isArray
+		for _, item := range items {
+			if _, isString := item.(string); !isString {
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		article := "a"
+		if strings.HasPrefix(kind, "i") || strings.HasPrefix(kind, "a") {
+			article = "an"
+		}
+		report(path, "must be %s %s, got %s", article, kind, strings.TrimSpace(string(raw)))
+	}
+	return valid
+}
+
+// runValidate implements `ralph-tui validate [--schema] [prd.json ...]` and
+// returns the exit code: 0 when every file is valid, 1 otherwise
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaFlag := fs.Bool("schema", false, "print the PRD JSON Schema and exit")
+	configFlag := fs.String("config", "", "config file naming the PRD to check when no path is given")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ralph-tui validate [--schema] [--config file] [prd.json ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *schemaFlag {
+		stdout.Write(prdSchema)
+		return 0
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		path, err := defaultPRDPath(*configFlag)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 2
+		}
+		paths = []string{path}
+	}
+
+	code := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			code = 1
+			continue
+		}
+		problems := ValidatePRD(data)
+		if len(problems) == 0 {
+			fmt.Fprintf(stdout, "%s: ok\n", path)
+			continue
+		}
+		code = 1
+		fmt.Fprintf(stdout, "%s: %d problem(s)\n", path, len(problems))
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "  %s\n", p)
+		}
+	}
+	return code
+}
+
+// defaultPRDPath resolves the PRD the way the TUI would without --prd
+func defaultPRDPath(configPath string) (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	exeDir := filepath.Dir(exePath)
+
+	var cfg Config
+	if configPath == "" {
+		cwd, _ := os.Getwd()
+		configPath = FindConfigFile(cwd, exeDir)
+	}
+	if configPath != "" {
+		if cfg, err = LoadConfigFile(configPath); err != nil {
+			return "", err
+		}
+	}
+	cfg.fillDefaults(exeDir)
+	return cfg.PRD, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// schemaNode is the part of prd.schema.json a constraint lives in
+type schemaNode map[string]any
+
+// wrongType is a value of some other type for each type the schema uses
+var wrongType = map[string]any{
+	"string":  1,
+	"integer": 1.5,
+	"boolean": "yes",
+	"array":   "not an array",
+	"object":  "not an object",
+}
+
+// TestValidatePRDEnforcesSchema breaks every constraint prd.schema.json
+// declares, one at a time, and expects ValidatePRD to report it, so the
+// schema and the validator cannot drift apart
+func TestValidatePRDEnforcesSchema(t *testing.T) {
+	var schema schemaNode
+	if err := json.Unmarshal(prdSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	story := schema["$defs"].(map[string]any)["story"].(map[string]any)
+
+	validStory := func() map[string]any {
+		return map[string]any{"id": "US-001", "title": "Story", "priority": 1, "passes": false}
+	}
+	expectProblem := func(path string, breakDoc func(root, story map[string]any)) {
+		t.Helper()
+		s := validStory()
+		root := map[string]any{"userStories": []any{s}}
+		breakDoc(root, s)
+		data, err := json.Marshal(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range ValidatePRD(data) {
+			if p.Path == path {
+				return
+			}
+		}
+		t.Errorf("%s: ValidatePRD accepts %s", path, data)
+	}
+
+	checkObject := func(node schemaNode, prefix string, target func(root, story map[string]any) map[string]any) {
+		for keyword := range node {
+			switch keyword {
+			case "$schema", "title", "description", "type", "properties", "required", "$defs":
+			default:
+				t.Errorf("schema keyword %q on %q is not covered by this test", keyword, prefix)
+			}
+		}
+		for _, key := range schemaStrings(node["required"]) {
+			expectProblem(prefix+key, func(root, story map[string]any) {
+				delete(target(root, story), key)
+			})
+		}
+
+		properties := node["properties"].(map[string]any)
+		for _, key := range sortedKeys(properties) {
+			prop := schemaNode(properties[key].(map[string]any))
+			path := prefix + key
+			set := func(value any) func(root, story map[string]any) {
+				return func(root, story map[string]any) { target(root, story)[key] = value }
+			}
+
+			for _, keyword := range sortedKeys(prop) {
+				switch keyword {
+				case "description", "$ref":
+				case "type":
+					expectProblem(path, set(wrongType[prop["type"].(string)]))
+				case "items":
+					items := prop["items"].(map[string]any)
+					if itemType, ok := items["type"].(string); ok {
+						expectProblem(path, set([]any{wrongType[itemType]}))
+					}
+				case "minLength":
+					short := ""
+					for i := 1; i < int(prop["minLength"].(float64)); i++ {
+						short += "x"
+					}
+					expectProblem(path, set(short))
+				case "pattern":
+					re := regexp.MustCompile(prop["pattern"].(string))
+					for _, candidate := range []string{"", " ", "x"} {
+						if !re.MatchString(candidate) {
+							expectProblem(path, set(candidate))
+							break
+						}
+					}
+				case "minimum":
+					expectProblem(path, set(prop["minimum"].(float64)-1))
+				case "uniqueItems":
+					if prop["uniqueItems"] == true {
+						expectProblem(path, set([]any{"US-002", "US-002"}))
+					}
+				default:
+					t.Errorf("schema keyword %q on %s is not covered by this test", keyword, path)
+				}
+			}
+		}
+	}
+
+	checkObject(schema, "", func(root, _ map[string]any) map[string]any { return root })
+	checkObject(schemaNode(story), "userStories[0].", func(_, story map[string]any) map[string]any { return story })
+}
+
+func schemaStrings(v any) []string {
+	var list []string
+	for _, item := range v.([]any) {
+		list = append(list, item.(string))
+	}
+	return list
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verification is the outcome of running the configured checks after an
+// iteration in which the agent marked stories as passing
+type Verification struct {
+	Stories  []string `json:"stories"`
+	Passed   bool     `json:"passed"`
+	Command  string   `json:"command,omitempty"`
+	ExitCode int      `json:"exitCode,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Summary describes the verdict in one line
+func (v Verification) Summary() string {
+	stories := strings.Join(v.Stories, ", ")
+	switch {
+	case v.Passed:
+		return "checks passed for " + stories
+	case v.Error != "":
+		return fmt.Sprintf("check `%s` failed (%s); %s not accepted", v.Command, v.Error, stories)
+	default:
+		return fmt.Sprintf("check `%s` failed (exit %d); %s not accepted", v.Command, v.ExitCode, stories)
+	}
+}
+
+// Verifier runs check commands in the project root
+type Verifier struct {
+	Checks  []string
+	Timeout time.Duration
+	Dir     string
+}
+
+// Run executes the checks in order, stopping at the first failure. Every
+// output line is passed to out.
+func (v Verifier) Run(stories []string, out func(stream, line string)) Verification {
+	result := Verification{Stories: stories, Passed: true}
+	for _, check := range v.Checks {
+		out("", "$ "+check)
+		code, err := v.runCheck(check, out)
+		if code == 0 && err == nil {
+			continue
+		}
+		result.Passed = false
+		result.Command = check
+		result.ExitCode = code
+		if err != nil {
+			result.Error = err.Error()
+		}
+		break
+	}
+	return result
+}
+
+func (v Verifier) runCheck(check string, out func(stream, line string)) (int, error) {
+	ctx := context.Background()
+	if v.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.Timeout)
+		defer cancel()
+	}
+
+	cmd := shellCommand(check)
+	cmd.Dir = v.Dir
+	setProcessGroup(cmd)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 1, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return 1, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 1, err
+	}
+
+	// Kill the whole group on timeout, not just the shell
+	stop := context.AfterFunc(ctx, func() { killProcessGroup(cmd) })
+	defer stop()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	forward := func(r io.Reader, stream string) {
+		defer wg.Done()
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			out(stream, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			out(stream, "✗ output unreadable from here on: "+err.Error())
+			io.Copy(io.Discard, r)
+		}
+	}
+	go forward(stdout, "")
+	go forward(stderr, "stderr")
+	wg.Wait()
+
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return -1, fmt.Errorf("timed out after %s", v.Timeout)
+	}
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 1, err
+}
+
+// newlyPassing returns the stories that pass in after but not in before
+func newlyPassing(before, after []Story) []string {
+	var ids []string
+	for _, story := range after {
+		if old := GetStoryByID(before, story.ID); story.Passes && (old == nil || !old.Passes) {
+			ids = append(ids, story.ID)
+		}
+	}
+	return ids
+}
+
+// RejectStories reverts the passes flag of the verified stories and records
+// why in their notes
+func RejectStories(prdPath string, v Verification, now time.Time) error {
+	doc, err := LoadPRDDocument(prdPath)
+	if err != nil {
+		return err
+	}
+
+	note := fmt.Sprintf("[%s] Verification failed: %s", now.Format("2006-01-02 15:04"), v.Summary())
+	for _, id := range v.Stories {
+		story := doc.Story(id)
+		if story == nil {
+			continue
+		}
+
+		var notes string
+		if err := story.Get("notes", &notes); err != nil {
+			return err
+		}
+		if notes != "" {
+			notes += "\n"
+		}
+		if err := story.Set("passes", false); err != nil {
+			return err
+		}
+		if err := story.Set("notes", notes+note); err != nil {
+			return err
+		}
+	}
+
+	return doc.Save(prdPath)
+}
@@ -32,6 +32,21 @@ func (m Model) View() string {
 		return m.renderHelpScreen()
 	}
 
+	switch m.overlay {
+	case OverlayArchiveConfirm:
+		return m.renderArchiveConfirm()
+	case OverlayArchives:
+		return m.renderArchiveBrowser()
+	case OverlayLogs:
+		return m.renderLogBrowser()
+	case OverlayDiff:
+		return m.renderDiffView()
+	case OverlayEditor:
+		return m.renderEditor()
+	case OverlayDeleteConfirm:
+		return m.renderDeleteConfirm()
+	}
+
 	header := m.renderHeader()
 	progress := m.renderProgress()
 	mainContent := m.renderMainContent()
@@ -58,14 +73,33 @@ func (m Model) renderHelpScreen() string {
 		HeaderStyle.Render(" Ralph - Keyboard Shortcuts "),
 		"",
 		lipgloss.NewStyle().Bold(true).Render("Navigation:"),
-		"  tab          Switch between Stories and Output panels",
+		"  tab          Switch between Stories and Output panels (and worker panes)",
 		"  ↑/↓ or j/k   Scroll up/down in focused panel",
 		"  g            Jump to top",
 		"  G            Jump to bottom",
+		"  c            Expand/fold the output of the agent's tool calls",
 		"",
 		lipgloss.NewStyle().Bold(true).Render("Control:"),
 		"  r            Start/restart iteration",
-		"  q or Ctrl+C  Quit application",
+		"  Enter        Work on the selected story next (Stories panel)",
+		"  t            Toggle the dependency tree view of the Stories panel",
+		"  u            Retry the selected stuck story",
+		"  p            Pause/resume the loop after the current iteration",
+		"  z            Suspend/resume the running agent (SIGSTOP/SIGCONT)",
+		"  Q            Exit once the current iteration finishes",
+		"  q or Ctrl+C  Quit application (stops the agent; press twice to force)",
+		"",
+		lipgloss.NewStyle().Bold(true).Render("Runs:"),
+		"  A            Archive current run and reset progress.txt",
+		"  B            Browse archived runs",
+		"  L            Browse iteration logs (/ to search, n/N for matches)",
+		"  l            Show progress.txt learnings in place of Output (n/N entries)",
+		"  d            Show the commits and diff of the selected story",
+		"",
+		lipgloss.NewStyle().Bold(true).Render("Editing (Stories panel):"),
+		"  e / n        Edit the selected story / add a new one",
+		"  K / J        Move the selected story up/down in priority",
+		"  space / D    Toggle passes / delete the selected story",
 		"",
 		lipgloss.NewStyle().Bold(true).Render("Search:"),
 		"  /            Enter search mode (filter stories)",
@@ -91,6 +125,9 @@ func (m Model) renderHeader() string {
 		if m.prd.BranchName != "" {
 			projectInfo += fmt.Sprintf(" (%s)", m.prd.BranchName)
 		}
+		if m.runner != nil {
+			projectInfo += fmt.Sprintf(" · agent: %s", m.runner.Name())
+		}
 	}
 
 	iterationText := fmt.Sprintf("Iteration %d", m.currentIteration)
@@ -98,6 +135,17 @@ func (m Model) renderHeader() string {
 		iterationText = fmt.Sprintf("Iteration %d/%d", m.currentIteration, m.maxIterations)
 	}
 
+	switch {
+	case m.quitting:
+		iterationText += " · " + TimerStyle.Render("stopping…")
+	case m.stopAfterCurrent:
+		iterationText += " · " + TimerStyle.Render("exit after current")
+	case m.suspended:
+		iterationText += " · " + TimerStyle.Render("suspended")
+	case m.paused:
+		iterationText += " · " + TimerStyle.Render("paused")
+	}
+
 	help := HelpStyle.Render("q: quit │ tab: switch panel │ r: restart")
 
 	headerLine1 := lipgloss.JoinHorizontal(
@@ -153,7 +201,7 @@ func (m Model) renderProgress() string {
 
 func (m Model) renderProgressStats() string {
 	if len(m.storyDurations) == 0 {
-		return ""
+		return HelpStyle.Render(m.renderUsageStats())
 	}
 
 	var totalDuration time.Duration
@@ -177,6 +225,7 @@ func (m Model) renderProgressStats() string {
 	stats := fmt.Sprintf(" │ Avg: %s │ Est: %s",
 		formatDuration(avgDuration),
 		formatDuration(estimatedTime))
+	stats += m.renderUsageStats()
 
 	return HelpStyle.Render(stats)
 }
@@ -206,6 +255,9 @@ func (m Model) renderMainContent() string {
 
 	storiesPanel := m.renderStoriesPanel(leftWidth, panelHeight)
 	outputPanel := m.renderOutputPanel(rightWidth, panelHeight)
+	if m.showLearnings {
+		outputPanel = m.renderLearningsPanel(rightWidth, panelHeight)
+	}
 
 	return lipgloss.JoinHorizontal(
 		lipgloss.Top,
@@ -223,13 +275,17 @@ func (m Model) renderStoriesPanel(width, height int) string {
 	}
 
 	title := PanelTitleStyle.Render("Stories")
+	if m.treeView {
+		title = PanelTitleStyle.Render("Stories · dependency tree")
+	}
 	if m.searchMode {
 		title = PanelTitleStyle.Render(fmt.Sprintf("Search: %s█", m.searchQuery))
 	}
 
-	displayStories := m.stories
-	if m.searchQuery != "" {
-		displayStories = m.filterStories()
+	displayStories := m.filterStories()
+	var depths map[string]int
+	if m.treeView {
+		_, depths = storyTree(m.stories)
 	}
 
 	var storyLines []string
@@ -246,7 +302,17 @@ func (m Model) renderStoriesPanel(width, height int) string {
 
 	for i := startIdx; i < endIdx; i++ {
 		story := displayStories[i]
-		line := m.renderStoryLine(story, width-4)
+		// the Learnings panel links its entries to the selection
+		selected := i == m.storyCursor && (m.focusedPanel == PanelStories || m.focusedPanel == PanelLearnings)
+		indent := ""
+		if depth := depths[story.ID]; depth > 0 {
+			indent = strings.Repeat("  ", depth-1) + "└ "
+		}
+		line := m.renderStoryLine(story, width-4-lipgloss.Width(indent), selected)
+		if indent != "" {
+			pad := strings.Repeat(" ", lipgloss.Width(indent))
+			line = indent + strings.ReplaceAll(line, "\n", "\n"+pad)
+		}
 		storyLines = append(storyLines, line)
 	}
 
@@ -277,9 +343,13 @@ func (m Model) renderStoriesPanel(width, height int) string {
 		storyListContent = strings.Join(combined, "\n")
 	}
 
-	currentStory := GetStoryByID(m.stories, m.currentStoryID)
+	currentStory := m.selectedStory()
 	if currentStory != nil && m.focusedPanel == PanelStories {
-		detailLines := []string{"", PanelTitleStyle.Render("Current Story Details")}
+		hint := "enter: work on this story next │ e: edit"
+		if m.stuck(*currentStory) {
+			hint += " │ u: retry"
+		}
+		detailLines := []string{"", PanelTitleStyle.Render(currentStory.ID + " Details"), HelpStyle.Render(hint)}
 		if currentStory.Description != "" {
 			detailLines = append(detailLines, HelpStyle.Render("Description: "+currentStory.Description))
 		}
@@ -289,6 +359,28 @@ func (m Model) renderStoriesPanel(width, height int) string {
 				detailLines = append(detailLines, HelpStyle.Render("  • "+criteria))
 			}
 		}
+		if len(currentStory.DependsOn) > 0 {
+			var deps []string
+			for _, id := range currentStory.DependsOn {
+				if dep := GetStoryByID(m.stories, id); dep != nil && dep.Passes {
+					id += " ✓"
+				}
+				deps = append(deps, id)
+			}
+			detailLines = append(detailLines, HelpStyle.Render("Depends on: "+strings.Join(deps, ", ")))
+		}
+		if u, ok := m.storyUsage()[currentStory.ID]; ok {
+			detailLines = append(detailLines, HelpStyle.Render("Spent: "+u.String()))
+		}
+		if n := m.storyLearnings(currentStory.ID); n > 0 && !m.showLearnings {
+			detailLines = append(detailLines, HelpStyle.Render(fmt.Sprintf("Learnings: %d entries in progress.txt (l to show)", n)))
+		}
+		if commits := m.storyCommits(currentStory.ID); len(commits) > 0 {
+			detailLines = append(detailLines, HelpStyle.Render("Commits (d for diff):"))
+			for _, c := range commits {
+				detailLines = append(detailLines, HelpStyle.Render("  "+c.Short()+" "+c.Subject))
+			}
+		}
 		storyListContent = lipgloss.JoinVertical(lipgloss.Left, storyListContent, strings.Join(detailLines, "\n"))
 	}
 
@@ -297,19 +389,57 @@ func (m Model) renderStoriesPanel(width, height int) string {
 	return style.Render(content)
 }
 
-func (m Model) renderStoryLine(story Story, maxWidth int) string {
+func (m Model) renderStoryLine(story Story, maxWidth int, selected bool) string {
 	var statusIcon string
 	var style lipgloss.Style
 
 	isCurrent := story.ID == m.currentStoryID
+	workerTag := ""
+	if w := m.workerOn(story.ID); w != nil {
+		isCurrent = true
+		workerTag = fmt.Sprintf(" [w%d]", w.ID)
+	}
+
+	unmet := UnmetDependencies(m.stories, story)
+	blocked := !story.Passes && len(unmet) > 0
+
+	verdict, verified := m.verdicts[story.ID]
+	verdictTag := ""
+	switch {
+	case m.awaitingChecks[story.ID]:
+		verdictTag = TimerStyle.Render(" ⧗ checks")
+	case verified && verdict.Passed && story.Passes:
+		verdictTag = StoryVerifiedStyle.Render(" ✔ verified")
+	case verified && !verdict.Passed && !story.Passes:
+		verdictTag = StoryRejectedStyle.Render(" ✗ checks failed")
+	}
+
+	attempts := m.attempts(story.ID)
+	stuck := m.stuck(story) && !(isCurrent && m.processRunning)
+	attemptTag := ""
+	switch {
+	case stuck:
+		attemptTag = fmt.Sprintf(" stuck after %d attempts", attempts)
+	case attempts > 0 && !story.Passes && m.maxAttemptsFor(story) > 0:
+		attemptTag = fmt.Sprintf(" ↻ %d/%d", attempts, m.maxAttemptsFor(story))
+	}
 
 	switch {
 	case story.Passes:
 		statusIcon = SuccessIcon
 		style = StoryDoneStyle
+	case stuck:
+		statusIcon = ErrorIcon
+		style = StoryRejectedStyle
+	case verified && !verdict.Passed && !isCurrent:
+		statusIcon = ErrorIcon
+		style = StoryPendingStyle
 	case isCurrent:
 		statusIcon = CurrentIcon
 		style = StoryCurrentStyle
+	case blocked:
+		statusIcon = BlockedIcon
+		style = StoryBlockedStyle
 	default:
 		statusIcon = PendingIcon
 		style = StoryPendingStyle
@@ -320,8 +450,16 @@ func (m Model) renderStoryLine(story Story, maxWidth int) string {
 	if story.Notes != "" {
 		notesIcon = "📝 "
 	}
+	pickedTag := ""
+	if story.ID == m.pickedStoryID {
+		pickedTag = " ⇢ next"
+	}
+	blockedTag := ""
+	if blocked {
+		blockedTag = " needs " + strings.Join(unmet, ", ")
+	}
 
-	metaWidth := len(criteriaCount) + len(notesIcon) + 1
+	metaWidth := len(criteriaCount) + len(notesIcon) + len(pickedTag) + len(workerTag) + len(blockedTag) + lipgloss.Width(attemptTag) + lipgloss.Width(verdictTag) + 1
 	titleMaxLen := maxWidth - storyIDWidth - metaWidth
 	if titleMaxLen < minTitleWidth {
 		titleMaxLen = minTitleWidth
@@ -332,7 +470,12 @@ func (m Model) renderStoryLine(story Story, maxWidth int) string {
 		title = title[:titleMaxLen-3] + "..."
 	}
 
-	mainLine := fmt.Sprintf("%s %s %s %s%s", statusIcon, story.ID, style.Render(title), notesIcon, HelpStyle.Render(criteriaCount))
+	id := story.ID
+	if selected {
+		id = StorySelectedStyle.Render(id)
+	}
+
+	mainLine := fmt.Sprintf("%s %s %s %s%s%s%s%s%s%s", statusIcon, id, style.Render(title), notesIcon, HelpStyle.Render(criteriaCount), StoryBlockedStyle.Render(blockedTag), StoryRejectedStyle.Render(attemptTag), verdictTag, TimerStyle.Render(workerTag), TimerStyle.Render(pickedTag))
 
 	if story.Description != "" && !isCurrent {
 		descMaxLen := maxWidth - 4
@@ -346,7 +489,17 @@ func (m Model) renderStoryLine(story Story, maxWidth int) string {
 	return mainLine
 }
 
+// storiesVisibleCount is the number of stories the Stories panel lists at once
+func (m Model) storiesVisibleCount() int {
+	panelHeight := max(minPanelHeight, m.height-totalUIOverhead)
+	return max(1, panelHeight-3)
+}
+
 func (m Model) renderOutputPanel(width, height int) string {
+	if m.parallel() {
+		return m.renderWorkerPanes(width, height)
+	}
+
 	var style lipgloss.Style
 	if m.focusedPanel == PanelOutput {
 		style = PanelActiveStyle.Width(width).Height(height)
@@ -367,23 +520,48 @@ func (m Model) renderOutputPanel(width, height int) string {
 func (m Model) renderStatusBar() string {
 	var statusText string
 
-	if m.prdUpdateNotif != "" {
-		statusText = lipgloss.NewStyle().Foreground(Green).Render(m.prdUpdateNotif)
+	if m.quitting {
+		statusText = TimerStyle.Render("⏳ Stopping agent… (q again to force quit)")
+	} else if m.suspended {
+		statusText = TimerStyle.Render("⏸ Agent suspended — press z to resume")
+	} else if m.prdError != nil {
+		statusText = StoryRejectedStyle.Render("✗ " + m.prdError.Error() + " — using the last good copy")
+	} else if m.statusNotif != "" {
+		statusText = lipgloss.NewStyle().Foreground(Green).Render(m.statusNotif)
 	} else if m.processDone {
 		statusText = ProgressBarFilled.Render("✓ All stories complete!")
+	} else if m.processRunning && m.parallel() {
+		statusText = m.renderWorkerStatus()
+		if m.stopAfterCurrent {
+			statusText += HelpStyle.Render(" │ exiting after these iterations")
+		} else if m.paused {
+			statusText += HelpStyle.Render(" │ pausing after these iterations")
+		}
 	} else if m.processRunning {
 		story := GetStoryByID(m.stories, m.currentStoryID)
 		if story != nil {
 			elapsed := formatElapsed(m.iterationStart)
 			statusText = fmt.Sprintf("▸ %s: %s    %s", story.ID, story.Title, TimerStyle.Render("⏱ "+elapsed))
+			if claim := renderClaim(story.ID, m.claimedStoryID); claim != "" {
+				statusText += " │ " + claim
+			}
 			if story.Notes != "" {
 				statusText += fmt.Sprintf(" │ %s", HelpStyle.Render(story.Notes))
 			}
 		} else {
 			statusText = "Running..."
 		}
+		if m.stopAfterCurrent {
+			statusText += HelpStyle.Render(" │ exiting after this iteration")
+		} else if m.paused {
+			statusText += HelpStyle.Render(" │ pausing after this iteration")
+		}
 	} else if m.processError != nil {
 		statusText = lipgloss.NewStyle().Foreground(Red).Render("Error: " + m.processError.Error())
+	} else if stuck := m.stuckStories(); len(stuck) > 0 && m.nextStory() == nil && m.currentIteration > 0 {
+		statusText = StoryRejectedStyle.Render("✗ Stuck: " + strings.Join(stuck, ", ") + " — select one and press u to retry")
+	} else if m.paused && m.currentIteration > 0 {
+		statusText = HelpStyle.Render("⏸ Paused — press p to resume")
 	} else {
 		statusText = HelpStyle.Render("Press 'r' to start")
 	}
@@ -430,13 +608,17 @@ func (m Model) renderScrollBar(totalItems, visibleCount, scrollPos, height int)
 }
 
 func (m Model) filterStories() []Story {
+	stories := m.stories
+	if m.treeView {
+		stories, _ = storyTree(m.stories)
+	}
 	if m.searchQuery == "" {
-		return m.stories
+		return stories
 	}
 
 	var filtered []Story
 	query := strings.ToLower(m.searchQuery)
-	for _, story := range m.stories {
+	for _, story := range stories {
 		if strings.Contains(strings.ToLower(story.ID), query) ||
 			strings.Contains(strings.ToLower(story.Title), query) ||
 			strings.Contains(strings.ToLower(story.Description), query) {
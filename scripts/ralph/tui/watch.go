@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// watchDebounce is how long a watched file must stay quiet before it is
+	// reloaded, so a burst of writes is read once
+	watchDebounce = 150 * time.Millisecond
+	// prdRetries and prdRetryDelay give a writer time to finish before a
+	// missing or half-written file is reported
+	prdRetries    = 5
+	prdRetryDelay = 200 * time.Millisecond
+)
+
+// fileWatcher watches the directory holding a file rather than the file, so
+// the watch survives editors and agents that save by writing a temporary
+// file and renaming it over the original, and a file that is deleted and
+// recreated
+type fileWatcher struct {
+	path    string
+	watcher *fsnotify.Watcher
+	err     error
+}
+
+func newFileWatcher(path string) *fileWatcher {
+	w := &fileWatcher{path: path}
+	w.watcher, w.err = fsnotify.NewWatcher()
+	if w.err != nil {
+		return w
+	}
+	if err := w.watcher.Add(filepath.Dir(path)); err != nil {
+		w.watcher.Close()
+		w.watcher, w.err = nil, err
+	}
+	return w
+}
+
+// watchPRDCmd waits for prd.json to change and reloads it. The caller runs
+// it again after every PRDUpdatedMsg.
+func watchPRDCmd(w *fileWatcher) tea.Cmd {
+	return func() tea.Msg {
+		if w.err != nil {
+			return ErrorMsg{Err: w.err}
+		}
+		if !w.waitForChange() {
+			return nil
+		}
+		prd, err := loadPRDSettled(w.path)
+		return PRDUpdatedMsg{PRD: prd, Err: err}
+	}
+}
+
+// watchProgressCmd waits for progress.txt to change and parses it again.
+// The caller runs it again after every ProgressUpdatedMsg.
+func watchProgressCmd(w *fileWatcher) tea.Cmd {
+	return func() tea.Msg {
+		if w.err != nil {
+			return ErrorMsg{Err: w.err}
+		}
+		if !w.waitForChange() {
+			return nil
+		}
+		progress, err := LoadProgress(w.path)
+		return ProgressUpdatedMsg{Progress: progress, Err: err}
+	}
+}
+
+// waitForChange blocks until the file is written, created, renamed or
+// removed and then stays quiet for watchDebounce. It returns false once the
+// watcher is closed.
+func (w *fileWatcher) waitForChange() bool {
+	name := filepath.Base(w.path)
+	var quiet <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return false
+			}
+			if filepath.Base(event.Name) != name || event.Op == fsnotify.Chmod {
+				continue
+			}
+			quiet = time.After(watchDebounce)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return false
+			}
+			// events may have been dropped; reload to be safe
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				quiet = time.After(watchDebounce)
+			}
+		case <-quiet:
+			return true
+		}
+	}
+}
+
+// loadPRDSettled reads prd.json, retrying a few times when it is missing or does not
+// parse, which is also what a save in progress looks like; only an error
+// that outlasts the retries is returned
+func loadPRDSettled(path string) (PRD, error) {
+	for attempt := 1; ; attempt++ {
+		prd, err := LoadPRD(path)
+		if err == nil || attempt == prdRetries {
+			return prd, err
+		}
+		time.Sleep(prdRetryDelay)
+	}
+}
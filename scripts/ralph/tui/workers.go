@@ -0,0 +1,383 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// worker is one slot of the parallel loop. Each runs its story in its own
+// git worktree and has its own output pane.
+type worker struct {
+	ID        int
+	StoryID   string
+	Iteration int
+	// Running covers the whole iteration, including worktree setup and the
+	// merge; Proc is only set while the agent itself runs
+	Running      bool
+	Proc         AgentProcess
+	Start        time.Time
+	LastOutputAt time.Time
+	SuspendedFor time.Duration
+	KillReason   string
+	// ClaimedStoryID is the story the agent says it works on
+	ClaimedStoryID string
+	// Usage sums the usage the agent reported this iteration
+	Usage TokenUsage
+
+	Lines       []outputLine
+	Viewport    viewport.Model
+	ExpandTools bool
+}
+
+func newWorkers(count int) []worker {
+	workers := make([]worker, count)
+	for i := range workers {
+		workers[i] = worker{ID: i + 1, Viewport: viewport.New(80, 10)}
+	}
+	return workers
+}
+
+// appendLine adds a timestamped line to the worker's pane
+func (w *worker) appendLine(t time.Time, line string) {
+	w.appendOutputLine(outputLine{Text: formatTimestamp(t) + " " + line})
+}
+
+func (w *worker) appendOutputLine(line outputLine) {
+	w.Lines = append(w.Lines, line)
+	w.Viewport.SetContent(renderOutputLines(w.Lines, w.ExpandTools))
+	w.Viewport.GotoBottom()
+}
+
+// parallel reports whether stories run in worktrees, several at a time
+func (m Model) parallel() bool {
+	return len(m.workers) > 0
+}
+
+// worker returns the worker with the given ID; 0 is the sequential loop
+func (m *Model) worker(id int) *worker {
+	if id < 1 || id > len(m.workers) {
+		return nil
+	}
+	return &m.workers[id-1]
+}
+
+// workerOn returns the worker running storyID, if any
+func (m Model) workerOn(storyID string) *worker {
+	for i := range m.workers {
+		if m.workers[i].Running && m.workers[i].StoryID == storyID {
+			return &m.workers[i]
+		}
+	}
+	return nil
+}
+
+func (m Model) anyWorkerRunning() bool {
+	for _, w := range m.workers {
+		if w.Running {
+			return true
+		}
+	}
+	return false
+}
+
+// agentRunning reports whether an iteration is in flight that quitting
+// would interrupt
+func (m Model) agentRunning() bool {
+	if m.parallel() {
+		return m.anyWorkerRunning()
+	}
+	return m.runningProc != nil
+}
+
+// activeProcs returns every agent process currently running
+func (m Model) activeProcs() []AgentProcess {
+	if !m.parallel() {
+		if m.runningProc == nil {
+			return nil
+		}
+		return []AgentProcess{m.runningProc}
+	}
+	var procs []AgentProcess
+	for _, w := range m.workers {
+		if w.Proc != nil {
+			procs = append(procs, w.Proc)
+		}
+	}
+	return procs
+}
+
+// announce shows a status line in the Output panel, or in every busy
+// worker's pane
+func (m *Model) announce(line string) {
+	if !m.parallel() {
+		m.appendOutput(line)
+		return
+	}
+	for i := range m.workers {
+		if m.workers[i].Running {
+			m.workers[i].appendLine(time.Now(), line)
+		}
+	}
+}
+
+// nextWorkerStory picks the story for a free worker: the hand-picked one,
+// then the highest priority ready story that is neither stuck nor held by
+// another worker
+func (m *Model) nextWorkerStory() (*Story, bool) {
+	if picked := GetStoryByID(m.stories, m.pickedStoryID); picked != nil && m.workerOn(picked.ID) == nil {
+		m.pickedStoryID = ""
+		return picked, true
+	}
+	for i := range m.stories {
+		story := m.stories[i]
+		if !story.Passes && DependenciesMet(m.stories, story) && !m.stuck(story) && m.workerOn(story.ID) == nil {
+			return &m.stories[i], false
+		}
+	}
+	return nil, false
+}
+
+// fillWorkers starts an iteration on every idle worker while the loop has
+// iterations and unclaimed stories left
+func (m *Model) fillWorkers() tea.Cmd {
+	var cmds []tea.Cmd
+	for i := range m.workers {
+		if m.workers[i].Running {
+			continue
+		}
+		if m.currentIteration >= m.maxIterations || m.processDone || m.agentFailed ||
+			m.paused || m.quitting || m.stopAfterCurrent {
+			break
+		}
+		story, pinned := m.nextWorkerStory()
+		if story == nil {
+			break
+		}
+		cmds = append(cmds, m.startWorker(&m.workers[i], *story, pinned))
+	}
+	m.processRunning = m.anyWorkerRunning()
+	return tea.Batch(cmds...)
+}
+
+// startWorker runs the next iteration on w in a worktree for story
+func (m *Model) startWorker(w *worker, story Story, pinned bool) tea.Cmd {
+	m.currentIteration++
+	now := time.Now()
+
+	w.StoryID = story.ID
+	w.Iteration = m.currentIteration
+	w.Running = true
+	w.Proc = nil
+	w.Start = now
+	w.LastOutputAt = now
+	w.SuspendedFor = 0
+	w.KillReason = ""
+	w.ClaimedStoryID = ""
+	w.Usage = TokenUsage{}
+	if len(w.Lines) > 0 {
+		w.Lines = append(w.Lines, outputLine{})
+	}
+	w.appendLine(now, strings.Repeat("═", 40))
+	w.appendLine(now, "Starting iteration "+strconv.Itoa(m.currentIteration)+" - "+story.ID)
+	w.appendLine(now, strings.Repeat("═", 40))
+
+	m.report(Event{Type: EventIterationStart, Iteration: m.currentIteration, StoryID: story.ID, Worker: w.ID})
+
+	spec := iterationSpec{
+		Worker:          w.ID,
+		Iteration:       m.currentIteration,
+		MaxIterations:   m.maxIterations,
+		StoryID:         story.ID,
+		Pinned:          pinned,
+		Runner:          m.runner,
+		PRDPath:         m.prdPath,
+		PromptPath:      m.promptPath,
+		ProgressPath:    m.progressPath,
+		ProjectRoot:     m.projectRoot,
+		RalphDir:        m.ralphDir,
+		PreviousLogPath: m.previousFailureLog(story.ID),
+		Verifier:        m.verifier,
+	}
+	wt := newWorktreeSpec(m.projectRoot, m.ralphDir, m.prd.BranchName, w.ID, story.ID)
+	return runWorkerIterationCmd(spec, wt, m.msgChan)
+}
+
+// workerOutput handles a line from a parallel worker. The completion signal
+// is ignored: a worker only sees its own copy of the PRD, so the loop is done
+// when the merged PRD says so.
+func (m *Model) workerOutput(msg OutputLineMsg) {
+	w := m.worker(msg.Worker)
+	if w == nil {
+		return
+	}
+	w.LastOutputAt = msg.Timestamp
+	if msg.Line != "" {
+		w.appendOutputLine(eventLine(msg))
+		m.report(outputEvent(msg, w.Iteration, w.StoryID))
+	}
+	if claimed, found := m.storyMatcher.Match(msg.Line); found {
+		m.noteClaim(w.ID, claimed)
+	}
+	if u := msg.Event.Usage; u != nil {
+		m.noteUsage(w.ID, *u)
+	}
+}
+
+// workerStarted records a parallel worker's agent process
+func (m *Model) workerStarted(msg ProcessStartedMsg) {
+	w := m.worker(msg.Worker)
+	if w == nil {
+		return
+	}
+	w.Proc = msg.Proc
+	w.Start = time.Now()
+	w.LastOutputAt = w.Start
+	m.storyStartTimes[msg.StoryID] = w.Start
+
+	switch {
+	case m.quitting:
+		// the agent started after the quit request went out
+		msg.Proc.Terminate(m.killGrace)
+	case m.suspended:
+		// resuming adds the whole suspension, of which only the part since
+		// the start counts for this worker
+		if msg.Proc.Suspend() == nil {
+			w.SuspendedFor = -time.Since(m.suspendedAt)
+		}
+	}
+}
+
+// workerExited records a parallel worker's iteration and hands the worker
+// its next story
+func (m Model) workerExited(msg ProcessExitedMsg) (tea.Model, tea.Cmd) {
+	listen := listenForOutputCmd(m.msgChan)
+	w := m.worker(msg.Worker)
+	if w == nil {
+		return m, listen
+	}
+	killReason := w.KillReason
+	w.Running = false
+	w.Proc = nil
+	w.KillReason = ""
+
+	wasPassing := false
+	if story := GetStoryByID(m.stories, msg.StoryID); story != nil {
+		wasPassing = story.Passes
+	}
+	saveRecord := m.recordIteration(msg, killReason, w.ClaimedStoryID, w.Usage)
+	w.Usage = TokenUsage{}
+	if story := GetStoryByID(m.stories, msg.StoryID); story != nil && story.Passes && !wasPassing {
+		m.storyDurations[msg.StoryID] = msg.End.Sub(msg.Start)
+	}
+	if msg.MergeErr != "" {
+		m.processError = fmt.Errorf("%s: %s", msg.StoryID, msg.MergeErr)
+	}
+
+	m.processRunning = m.anyWorkerRunning()
+	if !m.processRunning {
+		m.suspended = false
+	}
+
+	if m.quitting || m.stopAfterCurrent {
+		if m.processRunning {
+			return m, tea.Batch(listen, saveRecord)
+		}
+		return m, tea.Sequence(saveRecord, m.quitCmd())
+	}
+
+	cmds := []tea.Cmd{listen, saveRecord, m.fillWorkers()}
+	if m.headless && !m.processRunning {
+		return m, tea.Sequence(saveRecord, m.finishHeadless())
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// checkWorkerDeadlines applies the iteration and stall timeouts to each
+// parallel worker
+func (m *Model) checkWorkerDeadlines(now time.Time) {
+	if m.quitting || m.suspended {
+		return
+	}
+	for i := range m.workers {
+		w := &m.workers[i]
+		if w.Proc == nil || w.KillReason != "" {
+			continue
+		}
+		switch {
+		case m.iterationTimeout > 0 && now.Sub(w.Start)-w.SuspendedFor > m.iterationTimeout:
+			w.KillReason = "timed out after " + formatDuration(m.iterationTimeout)
+		case m.stallTimeout > 0 && now.Sub(w.LastOutputAt) > m.stallTimeout:
+			w.KillReason = "stalled: no output for " + formatDuration(m.stallTimeout)
+		default:
+			continue
+		}
+
+		w.Proc.Terminate(m.killGrace)
+		w.appendLine(now, "⚠ Iteration "+strconv.Itoa(w.Iteration)+" "+w.KillReason+", stopping agent")
+		m.report(Event{Type: EventTimeout, Iteration: w.Iteration, StoryID: w.StoryID, Worker: w.ID, Message: w.KillReason})
+	}
+}
+
+// focusedViewport is the output pane that scroll keys act on
+func (m *Model) focusedViewport() *viewport.Model {
+	if m.focusedPanel == PanelLearnings {
+		return &m.learningsViewport
+	}
+	if w := m.worker(m.focusedWorker); w != nil {
+		return &w.Viewport
+	}
+	return &m.outputViewport
+}
+
+// cycleFocus moves focus from Stories through the output pane or each
+// worker's pane, or the Learnings panel when it is shown, and back
+func (m *Model) cycleFocus() {
+	switch {
+	case m.showLearnings && m.focusedPanel == PanelStories:
+		m.focusedPanel = PanelLearnings
+	case m.showLearnings:
+		m.focusedPanel = PanelStories
+	case m.focusedPanel == PanelStories:
+		m.focusedPanel = PanelOutput
+		if m.parallel() {
+			m.focusedWorker = 1
+		}
+	case m.parallel() && m.focusedWorker < len(m.workers):
+		m.focusedWorker++
+	default:
+		m.focusedPanel = PanelStories
+		m.focusedWorker = 0
+	}
+}
+
+// workerPaneHeights splits the Output panel's height between the workers'
+// panes, each of which adds a two-line border of its own
+func workerPaneHeights(total, count int) []int {
+	inner := total + 2 - 2*count
+	heights := make([]int, count)
+	for i := range heights {
+		heights[i] = inner / count
+		if i < inner%count {
+			heights[i]++
+		}
+	}
+	return heights
+}
+
+// resizeWorkerViewports fits the worker panes into the Output panel
+func (m *Model) resizeWorkerViewports() {
+	if !m.parallel() {
+		return
+	}
+	panelHeight := max(minPanelHeight, m.height-totalUIOverhead)
+	width := m.width - (m.width/2 - panelHorizontalPad) - panelGap
+	for i, h := range workerPaneHeights(panelHeight, len(m.workers)) {
+		m.workers[i].Viewport.Width = max(20, width-4)
+		m.workers[i].Viewport.Height = max(1, h-2)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// renderWorkerPanes stacks one output pane per worker in the Output panel's
+// place
+func (m Model) renderWorkerPanes(width, height int) string {
+	var panes []string
+	for i, h := range workerPaneHeights(height, len(m.workers)) {
+		w := m.workers[i]
+
+		style := PanelStyle
+		if m.focusedPanel == PanelOutput && m.focusedWorker == w.ID {
+			style = PanelActiveStyle
+		}
+
+		title := fmt.Sprintf("Worker %d", w.ID)
+		switch {
+		case w.Running:
+			title += " · " + w.StoryID + " · " + formatElapsed(w.Start)
+			if w.ClaimedStoryID != "" && w.ClaimedStoryID != w.StoryID {
+				title += " · " + renderClaim(w.StoryID, w.ClaimedStoryID)
+			}
+		case w.StoryID != "":
+			title += " · idle"
+		}
+
+		// the viewport wraps long lines, which would push the pane below
+		// past its share, so they are cut instead
+		vp := w.Viewport
+		vp.Width = width - 4
+		vp.Height = max(1, h-2)
+		vp.SetContent(lipgloss.NewStyle().MaxWidth(vp.Width).Render(renderOutputLines(w.Lines, m.expandTools)))
+		content := lipgloss.JoinVertical(lipgloss.Left, PanelTitleStyle.Render(title), vp.View())
+		panes = append(panes, style.Width(width).Height(h).Render(content))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, panes...)
+}
+
+// renderWorkerStatus lists what each busy worker is doing
+func (m Model) renderWorkerStatus() string {
+	var parts []string
+	for _, w := range m.workers {
+		if w.Running {
+			parts = append(parts, fmt.Sprintf("w%d %s %s", w.ID, w.StoryID, TimerStyle.Render("⏱ "+formatElapsed(w.Start))))
+		}
+	}
+	return "▸ " + strings.Join(parts, " │ ")
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const worktreesDirName = "worktrees"
+
+// mergeMu serialises everything that touches the main worktree: branch
+// setup, adding worktrees and merging finished stories back
+var mergeMu sync.Mutex
+
+// worktreeSpec places a parallel worker's iteration in its own worktree
+type worktreeSpec struct {
+	// MainRoot is the project root of the main worktree
+	MainRoot string
+	// Base is the PRD branch stories are branched from and merged into
+	Base string
+	// StoryBranch is the branch the worker commits to
+	StoryBranch string
+	// Path is where the worktree is checked out
+	Path string
+}
+
+// newWorktreeSpec lays out worker id's worktree for a story
+func newWorktreeSpec(mainRoot, ralphDir, base string, worker int, storyID string) worktreeSpec {
+	return worktreeSpec{
+		MainRoot:    mainRoot,
+		Base:        base,
+		StoryBranch: base + "--" + sanitizeFileName(storyID),
+		Path:        filepath.Join(ralphDir, runsDirName, worktreesDirName, fmt.Sprintf("w%d", worker)),
+	}
+}
+
+// inWorktree maps a path inside the main worktree to the same path in the
+// worker's worktree
+func (w worktreeSpec) inWorktree(path string) (string, error) {
+	rel, err := filepath.Rel(w.MainRoot, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is outside the project root %s", path, w.MainRoot)
+	}
+	return filepath.Join(w.Path, rel), nil
+}
+
+// checkWorktreeLayout reports why parallel workers cannot run, if they can't
+func checkWorktreeLayout(root, prdPath, branch string) error {
+	if branch == "" {
+		return errors.New("parallel workers need a branchName in the PRD to merge stories into")
+	}
+	if gitHead(root) == "" {
+		return errors.New("parallel workers need a git repository with at least one commit")
+	}
+	rel, err := filepath.Rel(root, prdPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return errors.New("parallel workers need the PRD inside the project's git repository")
+	}
+	return nil
+}
+
+// prepare checks out a fresh worktree on the story branch, reset to the tip
+// of the base branch, and copies the main worktree's seed files into it so
+// the agent starts from the latest PRD and progress rather than the
+// committed ones
+func (w worktreeSpec) prepare(seed ...string) (string, error) {
+	mergeMu.Lock()
+	defer mergeMu.Unlock()
+
+	did, err := EnsureBranch(w.MainRoot, w.Base)
+	if err != nil {
+		return "", err
+	}
+
+	gitRun(w.MainRoot, "worktree", "prune")
+	if _, err := os.Stat(w.Path); err == nil {
+		if err := gitRun(w.MainRoot, "worktree", "remove", "--force", w.Path); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(w.Path), 0o755); err != nil {
+		return "", err
+	}
+	if err := gitRun(w.MainRoot, "worktree", "add", "-B", w.StoryBranch, w.Path, w.Base); err != nil {
+		return "", err
+	}
+
+	for _, path := range seed {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		dst, err := w.inWorktree(path)
+		if err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(dst, data, 0o644); err != nil {
+			return "", err
+		}
+	}
+	return did, nil
+}
+
+// remove deletes the worktree, and the story branch too once it is merged;
+// an unmerged branch is kept for inspection until the story is retried
+func (w worktreeSpec) remove(merged bool) error {
+	if err := gitRun(w.MainRoot, "worktree", "remove", "--force", w.Path); err != nil {
+		return err
+	}
+	if merged {
+		return gitRun(w.MainRoot, "branch", "--quiet", "-D", w.StoryBranch)
+	}
+	return nil
+}
+
+// commitLeftovers commits anything the agent left uncommitted, so removing
+// the worktree loses nothing
+func (w worktreeSpec) commitLeftovers(storyID string) (bool, error) {
+	status, err := gitOutput(w.Path, "status", "--porcelain")
+	if err != nil || status == "" {
+		return false, err
+	}
+	if err := gitRun(w.Path, "add", "--all"); err != nil {
+		return false, err
+	}
+	if err := gitRun(w.Path, "commit", "--quiet", "-m", "chore: ["+storyID+"] uncommitted changes left by the agent"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// merge merges the story branch into the base branch in the main worktree.
+// prd.json and progress.txt are merged by ralph rather than git — only the
+// story's own entry and the progress appended by the worker are taken — so
+// concurrent workers never conflict on them.
+func (w worktreeSpec) merge(storyID, prdPath, progressPath, baseProgress string) error {
+	mergeMu.Lock()
+	defer mergeMu.Unlock()
+
+	if branch := gitCurrentBranch(w.MainRoot); branch != w.Base {
+		return fmt.Errorf("main worktree is on %q instead of %s", branch, w.Base)
+	}
+
+	workerPRD, err := w.inWorktree(prdPath)
+	if err != nil {
+		return err
+	}
+	workerProgress, _ := w.inWorktree(progressPath)
+	workerDoc, err := LoadPRDDocument(workerPRD)
+	if err != nil {
+		return err
+	}
+	workerLog, _ := os.ReadFile(workerProgress)
+
+	// Take ralph's files out of git's way, remembering their current content
+	saved := make(map[string][]byte)
+	for _, path := range []string{prdPath, progressPath} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		saved[path] = data
+		rel, _ := filepath.Rel(w.MainRoot, path)
+		if gitRun(w.MainRoot, "checkout", "HEAD", "--", rel) != nil {
+			os.Remove(path)
+		}
+	}
+	restore := func() {
+		for path, data := range saved {
+			os.WriteFile(path, data, 0o644)
+		}
+	}
+
+	if err := gitRun(w.MainRoot, "merge", "--no-ff", "--no-commit", w.StoryBranch); err != nil {
+		if conflicts, _ := gitOutput(w.MainRoot, "diff", "--name-only", "--diff-filter=U"); conflicts == "" {
+			gitRun(w.MainRoot, "merge", "--abort")
+			restore()
+			return err
+		}
+	}
+
+	// Resolve ralph's files from their pre-merge content
+	if err := writeMergedPRD(prdPath, saved[prdPath], workerDoc, storyID); err != nil {
+		gitRun(w.MainRoot, "merge", "--abort")
+		restore()
+		return err
+	}
+	progress := mergeProgress(string(saved[progressPath]), baseProgress, string(workerLog))
+	if err := os.WriteFile(progressPath, []byte(progress), 0o644); err != nil {
+		gitRun(w.MainRoot, "merge", "--abort")
+		restore()
+		return err
+	}
+	for _, path := range []string{prdPath, progressPath} {
+		rel, _ := filepath.Rel(w.MainRoot, path)
+		gitRun(w.MainRoot, "add", "--", rel)
+	}
+
+	if conflicts, _ := gitOutput(w.MainRoot, "diff", "--name-only", "--diff-filter=U"); conflicts != "" {
+		gitRun(w.MainRoot, "merge", "--abort")
+		restore()
+		return fmt.Errorf("conflicts in %s; %s left for a manual merge", strings.ReplaceAll(conflicts, "\n", ", "), w.StoryBranch)
+	}
+
+	msg := fmt.Sprintf("Merge %s: [%s]", w.StoryBranch, storyID)
+	if err := gitRun(w.MainRoot, "commit", "--quiet", "-m", msg); err != nil {
+		gitRun(w.MainRoot, "merge", "--abort")
+		restore()
+		return err
+	}
+	return nil
+}
+
+// writeMergedPRD applies the worker's copy of a story to the main prd.json
+func writeMergedPRD(path string, current []byte, worker *PRDDocument, storyID string) error {
+	doc, err := ParsePRDDocument(current)
+	if err != nil {
+		return err
+	}
+
+	from, to := worker.Story(storyID), doc.Story(storyID)
+	if from == nil || to == nil {
+		return fmt.Errorf("story %s missing from prd.json", storyID)
+	}
+	for _, key := range []string{"passes", "notes"} {
+		var value any
+		if err := from.Get(key, &value); err != nil {
+			return err
+		}
+		if value != nil {
+			if err := to.Set(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return doc.Save(path)
+}
+
+// mergeProgress appends to current what the worker added on top of base
+func mergeProgress(current, base, worker string) string {
+	added, ok := strings.CutPrefix(worker, base)
+	if !ok {
+		// The worker rewrote the file; keep only entries current lacks
+		known := make(map[string]bool)
+		for _, e := range ParseProgress(current).Entries {
+			known[e.Heading] = true
+		}
+		var b strings.Builder
+		for _, e := range ParseProgress(worker).Entries {
+			if !known[e.Heading] {
+				fmt.Fprintf(&b, "## %s\n%s\n---\n", e.Heading, e.Body)
+			}
+		}
+		added = b.String()
+	}
+	if added == "" {
+		return current
+	}
+	if current != "" && !strings.HasSuffix(current, "\n") {
+		current += "\n"
+	}
+	return current + strings.TrimLeft(added, "\n")
+}
+
+func runWorkerIterationCmd(spec iterationSpec, wt worktreeSpec, msgChan chan<- interface{}) tea.Cmd {
+	return func() tea.Msg {
+		msgChan <- runWorkerIteration(spec, wt, msgChan)
+		return nil
+	}
+}
+
+// runWorkerIteration runs an iteration in the worker's worktree and merges
+// the story back into the PRD branch once it passes
+func runWorkerIteration(spec iterationSpec, wt worktreeSpec, msgChan chan<- interface{}) ProcessExitedMsg {
+	failed := func(err error) ProcessExitedMsg {
+		now := time.Now()
+		return ProcessExitedMsg{Worker: spec.Worker, Iteration: spec.Iteration, StoryID: spec.StoryID,
+			ExitCode: 1, Err: err, Start: now, End: now}
+	}
+
+	mainPRD, mainProgress := spec.PRDPath, spec.ProgressPath
+	workerPRD, err := wt.inWorktree(mainPRD)
+	if err != nil {
+		return failed(err)
+	}
+	workerProgress, err := wt.inWorktree(mainProgress)
+	if err != nil {
+		return failed(err)
+	}
+	did, err := wt.prepare(mainPRD, mainProgress)
+	if err != nil {
+		return failed(err)
+	}
+	// Whatever the worker adds on top of this is merged back
+	base, _ := os.ReadFile(workerProgress)
+	baseProgress := string(base)
+	if did != "" {
+		spec.output(msgChan, "[git] "+did)
+	}
+	spec.output(msgChan, "[worktree] "+wt.StoryBranch+" at "+wt.Path)
+
+	spec.ProjectRoot = wt.Path
+	spec.PRDPath = workerPRD
+	spec.Branch = ""
+	spec.Rollback = false
+	exited := runIteration(spec, msgChan)
+
+	merged := false
+	if story := storyIn(exited.PRD, spec.StoryID); story != nil && story.Passes {
+		if committed, err := wt.commitLeftovers(spec.StoryID); err != nil {
+			spec.output(msgChan, "[worktree] committing leftovers failed: "+err.Error())
+		} else if committed {
+			spec.output(msgChan, "[worktree] committed changes the agent left uncommitted")
+		}
+
+		if err := wt.merge(spec.StoryID, mainPRD, mainProgress, baseProgress); err != nil {
+			exited.MergeErr = err.Error()
+			spec.output(msgChan, "[merge] "+wt.StoryBranch+" not merged: "+err.Error())
+		} else {
+			merged = true
+			spec.output(msgChan, "[merge] "+wt.StoryBranch+" merged into "+wt.Base)
+		}
+	}
+
+	if err := wt.remove(merged); err != nil {
+		spec.output(msgChan, "[worktree] "+err.Error())
+	}
+
+	exited.PRD = nil
+	if prd, err := LoadPRD(mainPRD); err == nil {
+		exited.PRD = &prd
+	}
+	return exited
+}
+
+func storyIn(prd *PRD, id string) *Story {
+	if prd == nil {
+		return nil
+	}
+	return GetStoryByID(prd.UserStories, id)
+}